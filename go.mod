@@ -5,9 +5,12 @@ go 1.20
 require (
 	github.com/cuberat/go-textparser v1.1.0
 	github.com/sirupsen/logrus v1.9.0
-	golang.org/x/exp v0.0.0-20230321023759-10a507213a29
-	google.golang.org/protobuf v1.28.1
+	github.com/yuin/goldmark v1.7.8
+	google.golang.org/protobuf v1.33.0
 	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c
 )
 
-require golang.org/x/sys v0.1.0 // indirect
+require (
+	github.com/google/go-cmp v0.5.8 // indirect
+	golang.org/x/sys v0.1.0 // indirect
+)
@@ -29,6 +29,7 @@ package docdata
 import (
 	// Built-in/core modules.
 
+	"fmt"
 	"strings"
 
 	desc_pb "google.golang.org/protobuf/types/descriptorpb"
@@ -46,6 +47,95 @@ type PluginOpts struct {
 	OutFormat     string          `json:"out_format"`
 	ProtoPaths    []string        `json:"proto_paths"`
 	PrettyPrint   bool            `json:"pretty_out"`
+
+	// Template used to build the `url` field of source locations.
+	SourceURLTemplate string `json:"source_url_template"`
+
+	// Value to substitute for `{ref}` in the source URL template.
+	SourceRef string `json:"source_ref"`
+
+	// Names of the doc tags to recognize in comments, without the leading
+	// `@`. Nil means the default set.
+	DocTags []string `json:"doc_tags"`
+
+	// Separator between the leading and trailing comments in descriptions.
+	// Nil means the default.
+	CommentSeparator *string `json:"comment_separator"`
+
+	// Maximum length of summaries. Nil means the default, and zero or less
+	// means summaries are not truncated.
+	SummaryWidth *int `json:"summary_width"`
+
+	// Custom options that hide the elements they are set on, as `name` or
+	// `name=value`.
+	HideOptions []string `json:"hide_options"`
+
+	// Doc tags that hide the elements they are set on. Nil means the default
+	// set.
+	HideTags []string `json:"hide_tags"`
+
+	// Patterns (as used by `path.Match`) for files that are stable.
+	StableFiles []string `json:"stable_files"`
+
+	// Fail if any TODO notes are found in stable files.
+	FailOnStableTodos bool `json:"fail_on_stable_todos"`
+
+	// Fail if any warnings are found.
+	FailOnWarnings bool `json:"fail_on_warnings"`
+
+	// Paths to YAML files with description overlays, applied in order.
+	Overlays []string `json:"overlays"`
+
+	// Variables to expand in comments, from the `var` option.
+	Vars map[string]string `json:"vars"`
+
+	// Paths to YAML files with variables to expand in comments.
+	VarsFiles []string `json:"vars_files"`
+
+	// Go templates to render, from the `template` option.
+	Templates []*TemplateSpec `json:"templates"`
+
+	// Directory with templates that replace the built-in templates for
+	// documentation output formats.
+	TemplateDir string `json:"template_dir"`
+
+	// How documentation output formats are split into files: "package" (the
+	// default) or "file".
+	SplitBy string `json:"split_by"`
+
+	// Title and version of the API, for output formats such as OpenAPI.
+	APITitle   string `json:"api_title"`
+	APIVersion string `json:"api_version"`
+
+	// Write a single JSON Schema document with all of the definitions,
+	// instead of a document for each message.
+	SchemaBundle bool `json:"schema_bundle"`
+
+	// Number of levels of field types to follow in diagrams. Zero means no
+	// limit.
+	DiagramDepth int `json:"diagram_depth"`
+
+	// Leave the well-known types (`google.protobuf.*`) out of diagrams.
+	DiagramExcludeWellKnown bool `json:"diagram_exclude_well_known"`
+
+	// Group the nodes of diagrams by package.
+	DiagramCluster bool `json:"diagram_cluster"`
+
+	// Define the Docusaurus sidebar in `sidebars.js`, instead of with
+	// `_category_.json` files.
+	DocusaurusSidebars bool `json:"docusaurus_sidebars"`
+
+	// Render descriptions as Markdown.
+	Markdown bool `json:"markdown"`
+}
+
+// A template to render, and the name of the file to write the output to.
+type TemplateSpec struct {
+	Path string `json:"path"`
+
+	// Name of the output file. Empty means the base name of the template,
+	// with any `.tmpl` suffix removed.
+	OutFile string `json:"out_file"`
 }
 
 type CompilerDiag struct {
@@ -63,6 +153,24 @@ type CompilerDiag struct {
 type Config struct {
 	PluginOpts   *PluginOpts
 	CompilerDiag *CompilerDiag
+
+	// Variables to expand in comments, from the vars files and `var`
+	// options.
+	Vars map[string]string
+}
+
+// Location of an element in a protobuf specification file. Line and column
+// numbers are 1-based.
+type SourceLocation struct {
+	File        string `json:"file"`
+	StartLine   int32  `json:"start_line"`
+	StartColumn int32  `json:"start_column"`
+	EndLine     int32  `json:"end_line"`
+	EndColumn   int32  `json:"end_column"`
+
+	// Link to the source, built from the `source_url_template` plugin
+	// option. Empty if that option is not provided.
+	URL string `json:"url"`
 }
 
 type CommentData struct {
@@ -70,14 +178,114 @@ type CommentData struct {
 	LeadingComments         string   `json:"leading_comments"`
 	TrailingComments        string   `json:"trailing_comments"`
 	LeadingDetachedComments []string `json:"leading_detached_comments"`
+
+	// Doc tags (e.g., `@since v2.3`) pulled out of the comments, keyed by tag
+	// name. Each tag maps to a list, since tags can be repeated.
+	Tags map[string][]string `json:"tags"`
+
+	// Short summary of the element, for use in indexes, tables, and tooltips.
+	Summary string `json:"summary"`
+
+	// References to other elements found in the comments.
+	Links []*CommentLink `json:"links"`
+
+	// Code examples from fenced code blocks and `@example` tags.
+	Examples []*Example `json:"examples"`
+
+	// The comments as provided by the protobuf compiler, before
+	// normalization.
+	RawComments RawComments `json:"raw_comments"`
+
+	// The description rendered from Markdown to HTML, and broken down into
+	// blocks. These are only set if the `markdown` plugin option is given.
+	DescriptionHTML   string              `json:"description_html,omitempty"`
+	DescriptionBlocks []*DescriptionBlock `json:"description_blocks,omitempty"`
+
+	// Set by overlays to force the element to be hidden or shown, overriding
+	// the hide tags and options. Nil means no override.
+	Hidden *bool `json:"-" yaml:"-"`
+}
+
+type Example struct {
+	// Language of the example, e.g., "json". Examples without a language
+	// that are valid JSON are given the language "json".
+	Language string `json:"language"`
+
+	Title string `json:"title"`
+	Body  string `json:"body"`
+
+	// Where the example came from: "code_block" for a fenced code block in
+	// the description, or "tag" for an `@example` tag.
+	Origin string `json:"origin"`
+}
+
+type CommentLink struct {
+	// The reference as written in the comment, e.g., "[TesterRequest]".
+	Text string `json:"text"`
+
+	// Fully-qualified name of the referenced element.
+	Target string `json:"target"`
+
+	// Kind of the referenced element, e.g., "message", "field", or
+	// "enum_value".
+	Kind string `json:"kind"`
+}
+
+type RawComments struct {
+	Leading         string   `json:"leading"`
+	Trailing        string   `json:"trailing"`
+	LeadingDetached []string `json:"leading_detached"`
+}
+
+// A top-level block from a Markdown description. Which fields are set depends
+// on the type of block.
+type DescriptionBlock struct {
+	// One of "paragraph", "heading", "list", "code", "quote", "rule", or
+	// "table".
+	Type string `json:"type"`
+
+	// Plain text of the block, without inline markup. Set for paragraphs,
+	// headings, and quotes.
+	Text string `json:"text,omitempty"`
+
+	// HTML rendering of the block.
+	HTML string `json:"html"`
+
+	// Heading level (1-6).
+	Level int `json:"level,omitempty"`
+
+	// Whether a list is numbered, and the plain text of each list item.
+	Ordered bool     `json:"ordered,omitempty"`
+	Items   []string `json:"items,omitempty"`
+
+	// The language given for a fenced code block, if any, and the code.
+	Language string `json:"language,omitempty"`
+	Code     string `json:"code,omitempty"`
+
+	// The plain text of the header and body cells of a table.
+	Header []string   `json:"header,omitempty"`
+	Rows   [][]string `json:"rows,omitempty"`
 }
 
 type FieldOptions struct {
-	CType      desc_pb.FieldOptions_CType  `json:"ctype"`
-	Packed     bool                        `json:"packed"`
-	JSType     desc_pb.FieldOptions_JSType `json:"jstype"`
-	Lazy       bool                        `json:"lazy"`
-	Deprecated bool                        `json:"deprecated"`
+	CType           desc_pb.FieldOptions_CType              `json:"ctype"`
+	Packed          bool                                    `json:"packed"`
+	JSType          desc_pb.FieldOptions_JSType             `json:"jstype"`
+	Lazy            bool                                    `json:"lazy"`
+	UnverifiedLazy  bool                                    `json:"unverified_lazy"`
+	Deprecated      bool                                    `json:"deprecated"`
+	Weak            bool                                    `json:"weak"`
+	DebugRedact     bool                                    `json:"debug_redact"`
+	Retention       desc_pb.FieldOptions_OptionRetention    `json:"retention"`
+	Targets         []desc_pb.FieldOptions_OptionTargetType `json:"targets"`
+	EditionDefaults []*FieldEditionDefault                  `json:"edition_defaults"`
+	Features        map[string]any                          `json:"features"`
+	RawOptions      map[string]any                          `json:"raw_options"`
+}
+
+type FieldEditionDefault struct {
+	Edition desc_pb.Edition `json:"edition"`
+	Value   string          `json:"value"`
 }
 
 type FieldData struct {
@@ -97,22 +305,74 @@ type FieldData struct {
 	Options       *FieldOptions  `json:"options"`
 	CustomOptions map[string]any `json:"custom_options"`
 
+	// Name of the field in the JSON encoding of the message.
+	JSONName string `json:"json_name"`
+
+	// Whether the JSON name was set explicitly with the `json_name` option,
+	// rather than derived from the field name.
+	JSONNameExplicit bool `json:"json_name_explicit"`
+
+	// How the field is represented in the protobuf JSON mapping.
+	JSONMapping *JSONMapping `json:"protojson"`
+
 	// File this field was defined in.
 	DefinedIn string `json:"defined_in"`
+
+	// Where this element is defined.
+	Source *SourceLocation `json:"source"`
+}
+
+// Describes how a field is encoded by the protobuf JSON mapping (protojson).
+// See https://protobuf.dev/programming-guides/proto3/#json for details.
+type JSONMapping struct {
+	// JSON type of the field value: "string", "number", "boolean", "object",
+	// "array", "null", or "any".
+	JSONType string `json:"json_type"`
+
+	// "array" for repeated fields, "map" for map fields, or empty for
+	// singular fields.
+	Container string `json:"container"`
+
+	// JSON type of a single value. For repeated fields, this is the type of
+	// each element. For map fields, this is the type of each map value.
+	ValueJSONType string `json:"value_json_type"`
+
+	// Encoding of a single value. E.g., "int64_string", "base64",
+	// "enum_name", "timestamp".
+	ValueEncoding string `json:"value_encoding"`
+
+	// For map fields, the protobuf type of the map keys. Map keys are always
+	// encoded as JSON strings.
+	MapKeyType string `json:"map_key_type"`
+
+	// For map fields, the fully-qualified protobuf type of the map values.
+	MapValueType string `json:"map_value_type"`
+
+	// Fully-qualified name of the well-known type of the value, if any.
+	WellKnownType string `json:"well_known_type"`
+
+	// Human-readable description of the encoding.
+	Description string `json:"description"`
+}
+
+type OneofOptions struct {
+	Features   map[string]any `json:"features"`
+	RawOptions map[string]any `json:"raw_options"`
 }
 
 type OneOfData struct {
 	CommentData
-	Name     string `json:"name"`
-	FullName string `json:"full_name"`
-
-	// The only option for a oneof is the `uninterpreted_option` used to
-	// temporarily hold data for the parser. So there is no `Options`
-	// field here.
+	Name     string          `json:"name"`
+	FullName string          `json:"full_name"`
+	Options  *OneofOptions   `json:"options"`
+	Source   *SourceLocation `json:"source"`
 }
 
 type EnumValueOptions struct {
-	Deprecated bool `json:"deprecated"`
+	Deprecated  bool           `json:"deprecated"`
+	DebugRedact bool           `json:"debug_redact"`
+	Features    map[string]any `json:"features"`
+	RawOptions  map[string]any `json:"raw_options"`
 }
 
 type EnumValue struct {
@@ -121,29 +381,40 @@ type EnumValue struct {
 	Number        int32             `json:"number"`
 	Options       *EnumValueOptions `json:"options"`
 	CustomOptions map[string]any    `json:"custom_options"`
+	Source        *SourceLocation   `json:"source"`
 }
 
 type EnumOptions struct {
-	AllowAlias bool `json:"allow_alias"`
-	Deprecated bool `json:"deprecated"`
+	AllowAlias                         bool           `json:"allow_alias"`
+	Deprecated                         bool           `json:"deprecated"`
+	DeprecatedLegacyJsonFieldConflicts bool           `json:"deprecated_legacy_json_field_conflicts"`
+	Features                           map[string]any `json:"features"`
+	RawOptions                         map[string]any `json:"raw_options"`
 }
 
 type EnumData struct {
 	CommentData
 	Name          string         `json:"name"`
 	FullName      string         `json:"full_name"`
-	Description   string         `json:"description"`
 	Values        []*EnumValue   `json:"values"`
 	Options       *EnumOptions   `json:"options"`
 	CustomOptions map[string]any `json:"custom_options"`
 
 	// File this enum was defined in.
 	DefinedIn string `json:"defined_in"`
+
+	// Where this element is defined.
+	Source *SourceLocation `json:"source"`
 }
 
 type MessageOptions struct {
-	Deprecated bool `json:"deprecated"`
-	MapEntry   bool `json:"map_entry"`
+	MessageSetWireFormat               bool           `json:"message_set_wire_format"`
+	NoStandardDescriptorAccessor       bool           `json:"no_standard_descriptor_accessor"`
+	Deprecated                         bool           `json:"deprecated"`
+	MapEntry                           bool           `json:"map_entry"`
+	DeprecatedLegacyJsonFieldConflicts bool           `json:"deprecated_legacy_json_field_conflicts"`
+	Features                           map[string]any `json:"features"`
+	RawOptions                         map[string]any `json:"raw_options"`
 }
 
 type MessageData struct {
@@ -159,6 +430,9 @@ type MessageData struct {
 
 	// File this message was defined in.
 	DefinedIn string `json:"defined_in"`
+
+	// Where this element is defined.
+	Source *SourceLocation `json:"source"`
 }
 
 type FileExtension struct {
@@ -169,17 +443,76 @@ type FileExtension struct {
 	Type        string `json:"type"`
 	Extendee    string `json:"extendee"`
 
+	// Options for the extension field, e.g., `retention` and `targets` for
+	// custom options.
+	Options *FieldOptions `json:"options"`
+
+	// The `extend` block this extension is declared in.
+	ExtendDecl *ExtendDecl `json:"extend_decl"`
+
 	// File this extension was defined in.
 	DefinedIn string `json:"defined_in"`
+
+	// Where this element is defined.
+	Source *SourceLocation `json:"source"`
+}
+
+// An `extend` block. Several extensions may be declared in the same block.
+type ExtendDecl struct {
+	CommentData
+	Extendee string          `json:"extendee"`
+	Source   *SourceLocation `json:"source"`
 }
 
 type SyntaxDecl struct {
 	CommentData
-	Version string `json:"version"`
+	Version string          `json:"version"`
+	Source  *SourceLocation `json:"source"`
+}
+
+type PackageDecl struct {
+	CommentData
+	Name   string          `json:"name"`
+	Source *SourceLocation `json:"source"`
+}
+
+type ImportDecl struct {
+	CommentData
+
+	// Name of the imported file, as given in the import statement.
+	Name string `json:"name"`
+
+	// Package declared by the imported file.
+	Package string `json:"package"`
+
+	Public bool            `json:"public"`
+	Weak   bool            `json:"weak"`
+	Source *SourceLocation `json:"source"`
+}
+
+// A file-level `option` statement.
+type OptionDecl struct {
+	CommentData
+
+	// Name of the option, e.g., "go_package". Custom options are given by
+	// their fully-qualified name in parentheses, e.g.,
+	// "(MyServices.Tester.file_mnemonic)". Empty if the name of a custom
+	// option could not be resolved.
+	Name string `json:"name"`
+
+	// Field number of the option in `google.protobuf.FileOptions`.
+	FieldNumber int32 `json:"field_number"`
+
+	// Whether this is a custom option.
+	Custom bool            `json:"custom"`
+	Source *SourceLocation `json:"source"`
 }
 
 type MethodOptions struct {
-	Deprecated bool `json:"deprecated"`
+	Deprecated       bool                                   `json:"deprecated"`
+	IdempotencyLevel desc_pb.MethodOptions_IdempotencyLevel `json:"idempotency_level"`
+	Features         map[string]any                         `json:"features"`
+	RawOptions       map[string]any                         `json:"raw_options"`
 }
 
 type MethodData struct {
@@ -197,10 +530,15 @@ type MethodData struct {
 
 	// File this method was defined in.
 	DefinedIn string `json:"defined_in"`
+
+	// Where this element is defined.
+	Source *SourceLocation `json:"source"`
 }
 
 type ServiceOptions struct {
-	Deprecated bool `json:"deprecated"`
+	Deprecated bool           `json:"deprecated"`
+	Features   map[string]any `json:"features"`
+	RawOptions map[string]any `json:"raw_options"`
 }
 
 type ServiceData struct {
@@ -213,23 +551,37 @@ type ServiceData struct {
 
 	// File this service was defined in.
 	DefinedIn string `json:"defined_in"`
+
+	// Where this element is defined.
+	Source *SourceLocation `json:"source"`
 }
 
 type FileOptions struct {
-	JavaPackage          string `json:"java_package"`
-	JavaOuterClassname   string `json:"java_outer_classname"`
-	JavaMultipleFiles    bool   `json:"java_multiple_files"`
-	JavaStringCheckUtf8  bool   `json:"java_string_check_utf8"`
-	GoPackage            string `json:"go_package"`
-	Deprecated           bool   `json:"deprecated"`
-	CcEnableArenas       bool   `json:"cc_enable_arenas"`
-	ObjcClassPrefix      string `json:"objc_class_prefix"`
-	CsharpNamespace      string `json:"csharp_namespace"`
-	SwiftPrefix          string `json:"swift_prefix"`
-	PhpClassPrefix       string `json:"php_class_prefix"`
-	PhpNamespace         string `json:"php_namespace"`
-	PhpMetadataNamespace string `json:"php_metadata_namespace"`
-	RubyPackage          string `json:"ruby_package"`
+	JavaPackage               string                           `json:"java_package"`
+	JavaOuterClassname        string                           `json:"java_outer_classname"`
+	JavaMultipleFiles         bool                             `json:"java_multiple_files"`
+	JavaGenerateEqualsAndHash bool                             `json:"java_generate_equals_and_hash"`
+	JavaStringCheckUtf8       bool                             `json:"java_string_check_utf8"`
+	OptimizeFor               desc_pb.FileOptions_OptimizeMode `json:"optimize_for"`
+	GoPackage                 string                           `json:"go_package"`
+	CcGenericServices         bool                             `json:"cc_generic_services"`
+	JavaGenericServices       bool                             `json:"java_generic_services"`
+	PyGenericServices         bool                             `json:"py_generic_services"`
+	Deprecated                bool                             `json:"deprecated"`
+	CcEnableArenas            bool                             `json:"cc_enable_arenas"`
+	ObjcClassPrefix           string                           `json:"objc_class_prefix"`
+	CsharpNamespace           string                           `json:"csharp_namespace"`
+	SwiftPrefix               string                           `json:"swift_prefix"`
+	PhpClassPrefix            string                           `json:"php_class_prefix"`
+	PhpNamespace              string                           `json:"php_namespace"`
+	PhpMetadataNamespace      string                           `json:"php_metadata_namespace"`
+	RubyPackage               string                           `json:"ruby_package"`
+	Features                  map[string]any                   `json:"features"`
+
+	// All options set in the file declaration, including custom options and
+	// options not known to this plugin, keyed by option name. Options that
+	// could not be resolved to a name are keyed by field number.
+	RawOptions map[string]any `json:"raw_options"`
 }
 
 type FileData struct {
@@ -245,8 +597,50 @@ type FileData struct {
 	Syntax               *SyntaxDecl      `json:"syntax"`
 	CustomOptions        map[string]any   `json:"custom_options"`
 
+	// The `package` statement.
+	PackageDecl *PackageDecl `json:"package_decl"`
+
+	// The `import` statements, in the order they appear in the file.
+	Imports []*ImportDecl `json:"imports"`
+
+	// The file-level `option` statements, in the order they appear in the
+	// file.
+	OptionDecls []*OptionDecl `json:"option_decls"`
+
 	// File extensions that extend protobuf option messages.
 	DeclaredCustomOptions map[string][]*FileExtension `json:"declared_custom_options"`
+
+	// Location spanning the whole file.
+	Source *SourceLocation `json:"source"`
+
+	// Whether the file is marked as stable, either with a `@stable` tag or
+	// the `stable_file` plugin option.
+	Stable bool `json:"stable"`
+}
+
+type PackageData struct {
+	Name string `json:"name"`
+
+	// Description combined from the comments on the `package` statements in
+	// each file in the package.
+	Description string `json:"description"`
+
+	// Files declaring this package.
+	Files []string `json:"files"`
+
+	// Fully-qualified names of the messages (including nested messages),
+	// enumerations, services, and extensions defined in this package.
+	Messages   []string `json:"messages"`
+	Enums      []string `json:"enums"`
+	Services   []string `json:"services"`
+	Extensions []string `json:"extensions"`
+
+	// Other packages imported by files in this package.
+	Imports []string `json:"imports"`
+
+	// Imported files that are not being generated. Their packages are
+	// included in `Imports`.
+	ExternalImports []string `json:"external_imports"`
 }
 
 type TemplateData struct {
@@ -291,6 +685,106 @@ type TemplateData struct {
 
 	// Map of fully-qualified service names to lists of dependent files.
 	ServiceFileDeps map[string][]string `json:"service_file_deps"`
+
+	// List of protobuf package names, in the order they are first seen in
+	// `FileList`.
+	PackageList []string `json:"package_name_list"`
+
+	// Map of package names to package details.
+	PackageMap map[string]*PackageData `json:"package_map"`
+
+	// Problems found in the protobuf specifications, e.g., unresolved
+	// references in comments.
+	Warnings []*Warning `json:"warnings"`
+
+	// TODO, FIXME, and XXX notes found in comments.
+	TodoList []*TodoItem `json:"todo_list"`
+}
+
+type TodoItem struct {
+	// The marker that was found: "TODO", "FIXME", or "XXX".
+	Marker string `json:"marker"`
+
+	// Owner given in parentheses after the marker, e.g., "alice" for
+	// "TODO(alice): ...".
+	Owner string `json:"owner"`
+
+	// Rest of the line after the marker.
+	Text string `json:"text"`
+
+	// Fully-qualified name of the element whose comments have the note, if
+	// it has one, and the kind of element, e.g., "message" or "import".
+	Element string `json:"element"`
+	Kind    string `json:"kind"`
+
+	File string `json:"file"`
+
+	// Whether the file is marked as stable.
+	Stable bool `json:"stable"`
+
+	// Location of the element, if known.
+	Source *SourceLocation `json:"source"`
+}
+
+type Warning struct {
+	// Short identifier for the kind of problem, e.g., "unresolved_link".
+	Code    string `json:"code"`
+	Message string `json:"message"`
+
+	// File the problem was found in.
+	File string `json:"file"`
+
+	// Fully-qualified name of the element the problem was found in, if it has
+	// one.
+	Element string `json:"element"`
+
+	// Location of the element, if known.
+	Source *SourceLocation `json:"source"`
+}
+
+// Formats a warning for display, e.g., "file.proto:12:5: pkg.Foo: message".
+func (warning *Warning) String() string {
+	return format_diagnostic(warning.File, warning.Source, warning.Element,
+		warning.Message)
+}
+
+// Formats a note for display, e.g., "file.proto:12:5: pkg.Foo: TODO: text".
+func (todo *TodoItem) String() string {
+	return format_diagnostic(todo.File, todo.Source, todo.Element,
+		todo.Marker+": "+todo.Text)
+}
+
+// Returns the entry message for a map field, or nil if the field isn't a map.
+func (data *TemplateData) GetMapEntry(field *FieldData) *MessageData {
+	if field.Kind != "message" || field.Label != "repeated" {
+		return nil
+	}
+
+	entry := data.MessageMap[strings.TrimPrefix(field.FullTypeName, ".")]
+	if entry == nil || entry.Options == nil || !entry.Options.MapEntry ||
+		len(entry.Fields) != 2 {
+		return nil
+	}
+
+	return entry
+}
+
+func format_diagnostic(
+	file string,
+	source *SourceLocation,
+	element, message string,
+) string {
+	location := file
+	if source != nil {
+		location = fmt.Sprintf("%s:%d:%d", file, source.StartLine,
+			source.StartColumn)
+	}
+
+	if element != "" {
+		return fmt.Sprintf("%s: %s: %s", location, element, message)
+	}
+
+	return fmt.Sprintf("%s: %s", location, message)
 }
 
 func (ns Namespace) QualifyName(name string) string {
@@ -0,0 +1,641 @@
+package docgen
+
+// This file contains the code to post-process the comments attached to each
+// element, e.g., to pull out doc tags such as `@since`.
+
+// BSD 2-Clause License
+//
+// Copyright (c) 2023 Don Owens <don@regexguy.com>.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// * Redistributions of source code must retain the above copyright notice,
+//   this list of conditions and the following disclaimer.
+//
+// * Redistributions in binary form must reproduce the above copyright notice,
+//   this list of conditions and the following disclaimer in the documentation
+//   and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+import (
+	// Built-in/core modules.
+	"strings"
+	"unicode"
+
+	// Generated code.
+	// First-party modules.
+	docdata "github.com/cuberat/protoc-gen-docjson/internal/docdata"
+)
+
+// Tags recognized in comments when the `doc_tags` plugin option is not
+// provided.
+var DEFAULT_DOC_TAGS = []string{"since", "example", "see", "deprecated"}
+
+// Separator between the leading and trailing comments in a description when
+// the `comment_separator` plugin option is not provided.
+const DEFAULT_COMMENT_SEPARATOR = "\n\n"
+
+// Maximum length of summaries when the `summary_width` plugin option is not
+// provided.
+const DEFAULT_SUMMARY_WIDTH = 120
+
+// Doc tag used to override the summary. This is always recognized.
+const SUMMARY_TAG = "summary"
+
+// Post-processes the comments for every element in the data set.
+func process_comments(data *docdata.TemplateData, conf *docdata.Config) {
+	proc := new_comment_processor(conf)
+
+	for_each_comment_data(data, func(elem *comment_element) {
+		expand_comment_vars(data, elem, proc.vars)
+		leading :=
+			extract_doc_tags(elem.comments, proc.tag_set, proc.separator)
+		proc.derive_fields(elem, leading)
+	})
+}
+
+// Settings for processing comments, from the plugin options.
+type comment_processor struct {
+	tag_set       map[string]bool
+	separator     string
+	summary_width int
+	markdown      bool
+	vars          map[string]string
+}
+
+func new_comment_processor(conf *docdata.Config) *comment_processor {
+	tag_names := conf.PluginOpts.DocTags
+	if tag_names == nil {
+		tag_names = DEFAULT_DOC_TAGS
+	}
+
+	tag_set := make(map[string]bool, len(tag_names)+1)
+	for _, tag_name := range tag_names {
+		tag_set[tag_name] = true
+	}
+	tag_set[SUMMARY_TAG] = true
+	tag_set[STABLE_TAG] = true
+	for _, tag_name := range get_hide_tags(conf) {
+		tag_set[tag_name] = true
+	}
+
+	separator := conf.PluginOpts.CommentSeparator
+	if separator == nil {
+		default_separator := DEFAULT_COMMENT_SEPARATOR
+		separator = &default_separator
+	}
+
+	summary_width := DEFAULT_SUMMARY_WIDTH
+	if conf.PluginOpts.SummaryWidth != nil {
+		summary_width = *conf.PluginOpts.SummaryWidth
+	}
+
+	return &comment_processor{
+		tag_set:       tag_set,
+		separator:     *separator,
+		summary_width: summary_width,
+		markdown:      conf.PluginOpts.Markdown,
+		vars:          conf.Vars,
+	}
+}
+
+// Sets the fields derived from the description and tags of an element: the
+// examples, summary, and (if enabled) the rendered Markdown. The summary is
+// taken from `summary_text`, which is normally the leading comment without
+// its doc tags.
+func (proc *comment_processor) derive_fields(
+	elem *comment_element,
+	summary_text string,
+) {
+	extract_examples(elem.comments)
+	set_summary(elem.comments, summary_text, proc.summary_width)
+	if proc.markdown {
+		render_markdown(elem.comments)
+	}
+}
+
+// An element with comments, along with the context needed to process them.
+type comment_element struct {
+	comments *docdata.CommentData
+
+	// Kind of element, e.g., "message", "field", or "import".
+	kind string
+
+	// Fully-qualified name of the element. Empty for declarations such as
+	// `import` statements that don't have one.
+	full_name string
+
+	// Scope that names in the comments are resolved relative to. For
+	// elements that contain other elements (e.g., messages), this is the
+	// element itself. Otherwise, it is the enclosing element or package.
+	scope string
+
+	file   string
+	source *docdata.SourceLocation
+}
+
+// Calls `callback` for every element with comments in the data set, in file
+// order, then in declaration order within each file.
+func for_each_comment_data(
+	data *docdata.TemplateData,
+	callback func(elem *comment_element),
+) {
+	// Extensions declared in the same `extend` block share the declaration.
+	seen_extend_decls := make(map[*docdata.ExtendDecl]bool)
+
+	for _, file_name := range data.FileList {
+		file_data := data.FileMap[file_name]
+		pkg := file_data.Package
+
+		decl_element := func(
+			comments *docdata.CommentData,
+			kind string,
+			source *docdata.SourceLocation,
+		) *comment_element {
+			return &comment_element{
+				comments: comments,
+				kind:     kind,
+				scope:    pkg,
+				file:     file_name,
+				source:   source,
+			}
+		}
+
+		if file_data.Syntax != nil {
+			callback(decl_element(&file_data.Syntax.CommentData, "syntax",
+				file_data.Syntax.Source))
+		}
+		if file_data.PackageDecl != nil {
+			callback(decl_element(&file_data.PackageDecl.CommentData,
+				"package", file_data.PackageDecl.Source))
+		}
+		for _, import_decl := range file_data.Imports {
+			callback(decl_element(&import_decl.CommentData, "import",
+				import_decl.Source))
+		}
+		for _, option_decl := range file_data.OptionDecls {
+			callback(decl_element(&option_decl.CommentData, "option",
+				option_decl.Source))
+		}
+
+		for_each_message_comment_data(file_data.Messages, file_name, callback)
+		for_each_enum_comment_data(file_data.Enums, file_name, callback)
+
+		for _, svc := range file_data.Services {
+			callback(&comment_element{
+				comments:  &svc.CommentData,
+				kind:      "service",
+				full_name: svc.FullName,
+				scope:     svc.FullName,
+				file:      file_name,
+				source:    svc.Source,
+			})
+			for _, method := range svc.Methods {
+				callback(&comment_element{
+					comments:  &method.CommentData,
+					kind:      "method",
+					full_name: method.FullName,
+					scope:     svc.FullName,
+					file:      file_name,
+					source:    method.Source,
+				})
+			}
+		}
+
+		for _, ext := range file_data.Extensions {
+			callback(&comment_element{
+				comments:  &ext.CommentData,
+				kind:      "extension",
+				full_name: ext.FullName,
+				scope:     pkg,
+				file:      file_name,
+				source:    ext.Source,
+			})
+			if ext.ExtendDecl != nil && !seen_extend_decls[ext.ExtendDecl] {
+				seen_extend_decls[ext.ExtendDecl] = true
+				callback(decl_element(&ext.ExtendDecl.CommentData, "extend",
+					ext.ExtendDecl.Source))
+			}
+		}
+	}
+}
+
+func for_each_message_comment_data(
+	messages []*docdata.MessageData,
+	file_name string,
+	callback func(elem *comment_element),
+) {
+	for _, msg := range messages {
+		callback(&comment_element{
+			comments:  &msg.CommentData,
+			kind:      "message",
+			full_name: msg.FullName,
+			scope:     msg.FullName,
+			file:      file_name,
+			source:    msg.Source,
+		})
+		for _, field := range msg.Fields {
+			callback(&comment_element{
+				comments:  &field.CommentData,
+				kind:      "field",
+				full_name: field.FullName,
+				scope:     msg.FullName,
+				file:      file_name,
+				source:    field.Source,
+			})
+		}
+		for _, oneof := range msg.OneofDecls {
+			callback(&comment_element{
+				comments:  &oneof.CommentData,
+				kind:      "oneof",
+				full_name: oneof.FullName,
+				scope:     msg.FullName,
+				file:      file_name,
+				source:    oneof.Source,
+			})
+		}
+		for_each_enum_comment_data(msg.Enums, file_name, callback)
+		for_each_message_comment_data(msg.NestedMessages, file_name, callback)
+	}
+}
+
+func for_each_enum_comment_data(
+	enums []*docdata.EnumData,
+	file_name string,
+	callback func(elem *comment_element),
+) {
+	for _, enum_data := range enums {
+		callback(&comment_element{
+			comments:  &enum_data.CommentData,
+			kind:      "enum",
+			full_name: enum_data.FullName,
+			scope:     enum_data.FullName,
+			file:      file_name,
+			source:    enum_data.Source,
+		})
+		for _, enum_val := range enum_data.Values {
+			callback(&comment_element{
+				comments:  &enum_val.CommentData,
+				kind:      "enum_value",
+				full_name: qualify_name(enum_data.FullName, enum_val.Name),
+				scope:     enum_data.FullName,
+				file:      file_name,
+				source:    enum_val.Source,
+			})
+		}
+	}
+}
+
+// Returns the fully-qualified name of `name` within `scope`.
+func qualify_name(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+
+	return scope + "." + name
+}
+
+// Pulls recognized doc tags out of the leading and trailing comments into the
+// `Tags` map, and rebuilds the description without them, joining the leading
+// and trailing comments with `separator`. The `LeadingComments` and
+// `TrailingComments` fields are left as-is.
+func extract_doc_tags(
+	comments *docdata.CommentData,
+	tag_set map[string]bool,
+	separator string,
+) string {
+	comments.Tags = make(map[string][]string)
+
+	leading := parse_doc_tags(comments.LeadingComments, tag_set, comments.Tags)
+	trailing :=
+		parse_doc_tags(comments.TrailingComments, tag_set, comments.Tags)
+
+	comments.Description = get_description(leading, trailing, separator)
+
+	return leading
+}
+
+// Returns the leading comment with the recognized doc tags removed.
+func (proc *comment_processor) get_leading_text(
+	comments *docdata.CommentData,
+) string {
+	return parse_doc_tags(comments.LeadingComments, proc.tag_set,
+		make(map[string][]string))
+}
+
+// Removes lines starting with a recognized tag (e.g., `@since v2.3`) from the
+// comment text, adding the tag values to `tags`. A tag's value runs to the
+// next blank line or tag line, so that multi-line values such as examples are
+// kept together, with the relative indentation of the continuation lines
+// preserved. Returns the remaining text.
+func parse_doc_tags(
+	text string,
+	tag_set map[string]bool,
+	tags map[string][]string,
+) string {
+	if !strings.Contains(text, "@") {
+		return text
+	}
+
+	kept_lines := make([]string, 0)
+	cur_tag := ""
+	cur_value := ""
+	cont_lines := make([]string, 0)
+
+	// A value continues past blank lines while it has unclosed brackets
+	// (e.g., a multi-line JSON example) or an unclosed fenced code block.
+	depth := 0
+	in_fence := false
+	value_is_open := func() bool {
+		return depth > 0 || in_fence
+	}
+	track_value_line := func(line string) {
+		if is_fence_line(line) {
+			in_fence = !in_fence
+		} else if !in_fence {
+			depth = get_bracket_depth(line, depth)
+		}
+	}
+
+	flush_tag := func() {
+		if cur_tag != "" {
+			value := cur_value
+			if len(cont_lines) > 0 {
+				value = strings.TrimSpace(value + "\n" +
+					strings.Join(dedent_lines(cont_lines), "\n"))
+			}
+			tags[cur_tag] = append(tags[cur_tag], value)
+		}
+		cur_tag = ""
+		cur_value = ""
+		cont_lines = cont_lines[:0]
+		depth = 0
+		in_fence = false
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		if !value_is_open() {
+			if tag_name, value, ok := get_tag_line(trimmed, tag_set); ok {
+				flush_tag()
+				cur_tag = tag_name
+				cur_value = value
+				track_value_line(value)
+				continue
+			}
+		}
+
+		if cur_tag != "" {
+			if trimmed != "" || value_is_open() {
+				cont_lines = append(cont_lines, line)
+				track_value_line(trimmed)
+				continue
+			}
+			flush_tag()
+		}
+
+		kept_lines = append(kept_lines, line)
+	}
+	flush_tag()
+
+	return strings.TrimSpace(strings.Join(kept_lines, "\n"))
+}
+
+// Returns true if the line starts or ends a fenced code block.
+func is_fence_line(line string) bool {
+	line = strings.TrimSpace(line)
+
+	return strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~")
+}
+
+// Returns the bracket nesting depth after the given line, starting from
+// `depth`. Brackets in double-quoted strings are ignored.
+func get_bracket_depth(line string, depth int) int {
+	in_string := false
+	escaped := false
+	for _, char := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case in_string && char == '\\':
+			escaped = true
+		case char == '"':
+			in_string = !in_string
+		case in_string:
+		case char == '{' || char == '[':
+			depth++
+		case char == '}' || char == ']':
+			depth--
+		}
+	}
+
+	return depth
+}
+
+// Returns the tag name and the rest of the line if the line starts with a
+// recognized tag.
+func get_tag_line(
+	line string,
+	tag_set map[string]bool,
+) (tag_name, value string, ok bool) {
+	if !strings.HasPrefix(line, "@") {
+		return "", "", false
+	}
+
+	tag_name = line[1:]
+	if space_idx := strings.IndexAny(tag_name, " \t"); space_idx >= 0 {
+		tag_name, value = tag_name[:space_idx], tag_name[space_idx+1:]
+	}
+
+	if !tag_set[tag_name] {
+		return "", "", false
+	}
+
+	return tag_name, strings.TrimSpace(value), true
+}
+
+// Sets the summary from the `@summary` tag if there is one, otherwise from the
+// first sentence of the first paragraph of `text` (the leading comment), in
+// the style of Go doc comments. The summary is truncated to `width` characters on a word
+// boundary if `width` is greater than zero.
+func set_summary(comments *docdata.CommentData, text string, width int) {
+	summary := ""
+	if summary_tags := comments.Tags[SUMMARY_TAG]; len(summary_tags) > 0 {
+		summary = strings.Join(strings.Fields(summary_tags[0]), " ")
+	} else {
+		summary = get_first_sentence(text)
+	}
+
+	comments.Summary = truncate_summary(summary, width)
+}
+
+// Returns the first sentence of the first paragraph of the text, with white
+// space collapsed. A sentence ends at a period, question mark, or exclamation
+// mark followed by white space, except for a period after a single capital
+// letter (e.g., an initial).
+func get_first_sentence(text string) string {
+	paragraph, _, _ := strings.Cut(strings.TrimSpace(text), "\n\n")
+	paragraph = strings.Join(strings.Fields(paragraph), " ")
+
+	runes := []rune(paragraph)
+	for i := 0; i+1 < len(runes); i++ {
+		if runes[i+1] != ' ' {
+			continue
+		}
+
+		switch runes[i] {
+		case '?', '!':
+			return string(runes[:i+1])
+		case '.':
+			if i >= 1 && unicode.IsUpper(runes[i-1]) &&
+				(i == 1 || !unicode.IsLetter(runes[i-2])) {
+				continue
+			}
+			return string(runes[:i+1])
+		}
+	}
+
+	return paragraph
+}
+
+// Truncates the summary to at most `width` characters, breaking at a word
+// boundary and adding "..." if anything was removed. A summary is not
+// truncated if `width` is zero or less.
+func truncate_summary(summary string, width int) string {
+	runes := []rune(summary)
+	if width <= 0 || len(runes) <= width {
+		return summary
+	}
+
+	const ellipsis = "..."
+	limit := width - len(ellipsis)
+	if limit <= 0 {
+		return string(runes[:width])
+	}
+
+	cut := limit
+	for cut > 0 && runes[cut] != ' ' {
+		cut--
+	}
+	if cut == 0 {
+		// A single word longer than the width.
+		cut = limit
+	}
+
+	return strings.TrimRight(string(runes[:cut]), " ,;:") + ellipsis
+}
+
+// Normalizes the text of a comment:
+//
+//   - Trailing white space is removed from each line.
+//   - If the comment has a `*` gutter (a block comment starting with `/*` or
+//     `/**` on its own line, with each line starting with `*`), the gutter is
+//     removed, along with the `*` of a `/**` opener.
+//   - The indentation common to all non-blank lines is removed, so that the
+//     relative indentation of, e.g., code examples is kept.
+//   - Leading and trailing blank lines are removed, and runs of blank lines
+//     are collapsed to one, so that paragraph breaks are kept.
+func normalize_comment(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+
+	// The protobuf compiler removes the gutter itself, but leaves the `*` from
+	// a `/**` opener on its own line.
+	if strings.TrimSpace(lines[0]) == "*" {
+		lines[0] = ""
+	}
+
+	if has_star_gutter(lines) {
+		for i, line := range lines {
+			line = strings.TrimLeft(line, " \t")
+			line = strings.TrimPrefix(line, "*")
+			lines[i] = strings.TrimPrefix(line, " ")
+		}
+	}
+
+	lines = dedent_lines(lines)
+
+	out_lines := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if line == "" &&
+			(len(out_lines) == 0 || out_lines[len(out_lines)-1] == "") {
+			continue
+		}
+		out_lines = append(out_lines, line)
+	}
+
+	return strings.TrimRight(strings.Join(out_lines, "\n"), "\n")
+}
+
+// Returns true if the first line of the comment is blank, and every other
+// non-blank line starts with a `*` after any indentation.
+func has_star_gutter(lines []string) bool {
+	if len(lines) < 2 {
+		return false
+	}
+
+	if strings.TrimSpace(lines[0]) != "" {
+		return false
+	}
+
+	found_gutter := false
+	for _, line := range lines[1:] {
+		line = strings.TrimLeft(line, " \t")
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "*") {
+			return false
+		}
+		found_gutter = true
+	}
+
+	return found_gutter
+}
+
+// Removes the leading white space common to all non-blank lines. Blank lines
+// are returned as empty strings.
+func dedent_lines(lines []string) []string {
+	prefix := ""
+	have_prefix := false
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if !have_prefix {
+			prefix = indent
+			have_prefix = true
+			continue
+		}
+		for !strings.HasPrefix(indent, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+
+	out_lines := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			out_lines = append(out_lines, "")
+			continue
+		}
+		out_lines = append(out_lines, strings.TrimPrefix(line, prefix))
+	}
+
+	return out_lines
+}
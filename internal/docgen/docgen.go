@@ -34,6 +34,7 @@ import (
 	// Third-party modules.
 
 	log "github.com/sirupsen/logrus"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	desc_pb "google.golang.org/protobuf/types/descriptorpb"
 
 	// Generated code.
@@ -52,14 +53,25 @@ var CUSTOM_OPTION_TYPES = map[string]string{
 	".google.protobuf.EnumValueOptions": "enum_val",
 }
 
+// Builds the template data from the descriptors of the files to generate.
+// `all_file_descriptors` has the descriptors of all of the files in the
+// request, including imported files that aren't being generated, which are
+// used to resolve names defined outside of the generated files.
 func GenDocData(
 	conf *docdata.Config,
 	file_descriptors []*desc_pb.FileDescriptorProto,
 	files_to_generate map[string]bool,
+	all_file_descriptors []*desc_pb.FileDescriptorProto,
 ) (*docdata.TemplateData, error) {
 	template_data := &docdata.TemplateData{
 		FileList: make([]string, 0, len(file_descriptors)),
 		FileMap:  make(map[string]*docdata.FileData, len(file_descriptors)),
+		Warnings: make([]*docdata.Warning, 0),
+	}
+
+	file_packages := make(map[string]string, len(all_file_descriptors))
+	for _, desc_file := range all_file_descriptors {
+		file_packages[desc_file.GetName()] = desc_file.GetPackage()
 	}
 
 	for _, desc_file_info := range file_descriptors {
@@ -88,6 +100,10 @@ func GenDocData(
 			}
 		}
 
+		this_file.PackageDecl = &docdata.PackageDecl{Name: this_file.Package}
+		this_file.Imports = get_import_decls(desc_file_info, file_packages)
+		this_file.OptionDecls = make([]*docdata.OptionDecl, 0)
+
 		set_file_options(this_file, desc_file_info)
 
 		this_file.Syntax = new(docdata.SyntaxDecl)
@@ -125,6 +141,7 @@ func GenDocData(
 			}
 
 			this_extension.Extendee = extension.GetExtendee()
+			this_extension.Options = get_field_options(extension)
 
 			option_type, ok := CUSTOM_OPTION_TYPES[this_extension.Extendee]
 			if ok {
@@ -142,8 +159,16 @@ func GenDocData(
 	}
 
 	extensions.ProcessExtensions(template_data, file_descriptors, conf)
+	if err := load_comment_vars(conf); err != nil {
+		return nil, err
+	}
+	process_comments(template_data, conf)
+	collect_todos(template_data, conf)
 
+	resolve_option_decl_names(template_data, all_file_descriptors)
 	massage_data(template_data)
+	resolve_comment_links(template_data)
+	check_examples(template_data)
 
 	return template_data, nil
 }
@@ -158,20 +183,27 @@ func set_file_options(
 	}
 	this_file.Options = &docdata.FileOptions{
 
-		JavaPackage:          fopts.GetJavaPackage(),
-		JavaOuterClassname:   fopts.GetJavaOuterClassname(),
-		JavaMultipleFiles:    fopts.GetJavaMultipleFiles(),
-		JavaStringCheckUtf8:  fopts.GetJavaStringCheckUtf8(),
-		GoPackage:            fopts.GetGoPackage(),
-		Deprecated:           fopts.GetDeprecated(),
-		CcEnableArenas:       fopts.GetCcEnableArenas(),
-		ObjcClassPrefix:      fopts.GetObjcClassPrefix(),
-		CsharpNamespace:      fopts.GetCsharpNamespace(),
-		SwiftPrefix:          fopts.GetSwiftPrefix(),
-		PhpClassPrefix:       fopts.GetPhpClassPrefix(),
-		PhpNamespace:         fopts.GetPhpNamespace(),
-		PhpMetadataNamespace: fopts.GetPhpMetadataNamespace(),
-		RubyPackage:          fopts.GetRubyPackage(),
+		JavaPackage:               fopts.GetJavaPackage(),
+		JavaOuterClassname:        fopts.GetJavaOuterClassname(),
+		JavaMultipleFiles:         fopts.GetJavaMultipleFiles(),
+		JavaGenerateEqualsAndHash: fopts.GetJavaGenerateEqualsAndHash(),
+		JavaStringCheckUtf8:       fopts.GetJavaStringCheckUtf8(),
+		OptimizeFor:               fopts.GetOptimizeFor(),
+		GoPackage:                 fopts.GetGoPackage(),
+		CcGenericServices:         fopts.GetCcGenericServices(),
+		JavaGenericServices:       fopts.GetJavaGenericServices(),
+		PyGenericServices:         fopts.GetPyGenericServices(),
+		Deprecated:                fopts.GetDeprecated(),
+		CcEnableArenas:            fopts.GetCcEnableArenas(),
+		ObjcClassPrefix:           fopts.GetObjcClassPrefix(),
+		CsharpNamespace:           fopts.GetCsharpNamespace(),
+		SwiftPrefix:               fopts.GetSwiftPrefix(),
+		PhpClassPrefix:            fopts.GetPhpClassPrefix(),
+		PhpNamespace:              fopts.GetPhpNamespace(),
+		PhpMetadataNamespace:      fopts.GetPhpMetadataNamespace(),
+		RubyPackage:               fopts.GetRubyPackage(),
+		Features:                  get_features(fopts.GetFeatures()),
+		RawOptions:                get_raw_options(fopts),
 	}
 }
 
@@ -183,7 +215,9 @@ func massage_data(data *docdata.TemplateData) {
 		massage_enum_data(data, file_data.Enums)
 	}
 
+	add_json_mappings(data)
 	add_dependencies(data)
+	add_packages(data)
 }
 
 func add_dependencies(data *docdata.TemplateData) {
@@ -411,6 +445,8 @@ func set_service_options(
 
 	this_svc.Options = &docdata.ServiceOptions{
 		Deprecated: svc_opts.GetDeprecated(),
+		Features:   get_features(svc_opts.GetFeatures()),
+		RawOptions: get_raw_options(svc_opts),
 	}
 }
 
@@ -447,7 +483,10 @@ func set_method_options(
 	}
 
 	this_method.Options = &docdata.MethodOptions{
-		Deprecated: method_opts.GetDeprecated(),
+		Deprecated:       method_opts.GetDeprecated(),
+		IdempotencyLevel: method_opts.GetIdempotencyLevel(),
+		Features:         get_features(method_opts.GetFeatures()),
+		RawOptions:       get_raw_options(method_opts),
 	}
 }
 
@@ -467,63 +506,202 @@ func add_source_code_info(
 	source_info *desc_pb.SourceCodeInfo,
 	conf *docdata.Config,
 ) {
+	extend_decls := make([]*docdata.ExtendDecl, 0)
+
 	for _, location := range source_info.Location {
 		loc_path := location.Path
-		if loc_path == nil {
+		source := get_source_location(file_data.Name, location, conf)
+		if len(loc_path) == 0 {
+			// The location for the file as a whole.
+			file_data.Source = source
 			continue
 		}
 		desc_field_num := loc_path[0]
 
 		switch desc_field_num {
+		case 2: // package
+			package_decl := file_data.PackageDecl
+			package_decl.Source = source
+			set_comments(&package_decl.CommentData, location)
+		case 3: // import
+			if len(loc_path) == 2 {
+				import_decl := file_data.Imports[loc_path[1]]
+				import_decl.Source = source
+				set_comments(&import_decl.CommentData, location)
+			}
+		case 8: // file option
+			if len(loc_path) == 2 {
+				option_decl := get_option_decl(loc_path[1])
+				option_decl.Source = source
+				set_comments(&option_decl.CommentData, location)
+				file_data.OptionDecls =
+					append(file_data.OptionDecls, option_decl)
+			}
 		case 4: // message
 			msg := file_data.Messages[loc_path[1]]
-			add_msg_desc(loc_path[2:], msg, location)
+			add_msg_desc(loc_path[2:], msg, location, source)
 		case 5: // enum
 			this_enum := file_data.Enums[loc_path[1]]
-			add_enum_comments(loc_path[2:], this_enum, location)
+			add_enum_comments(loc_path[2:], this_enum, location, source)
 		case 6: // service
 			svc := file_data.Services[loc_path[1]]
-			add_service_comments(loc_path, svc, location)
+			add_service_comments(loc_path, svc, location, source)
 		case 7: // extension
-			add_extension_comments(loc_path[1:], file_data, location)
+			if len(loc_path) == 1 {
+				// An `extend` block. These get attached to the extensions
+				// declared within them once we have seen all of the locations.
+				extend_decl := &docdata.ExtendDecl{Source: source}
+				set_comments(&extend_decl.CommentData, location)
+				extend_decls = append(extend_decls, extend_decl)
+				continue
+			}
+			add_extension_comments(loc_path[1:], file_data, location, source)
 		case 12: // syntax
 			syntax := file_data.Syntax
-			syntax.LeadingDetachedComments =
-				clean_comments_slice(location.LeadingDetachedComments)
-			syntax.LeadingComments, syntax.TrailingComments,
-				syntax.Description = clean_comments(location)
+			syntax.Source = source
+			set_comments(&syntax.CommentData, location)
 		}
 
 	}
+
+	attach_extend_decls(file_data, extend_decls)
 }
 
-// If the provided slice is nil, return an empty slice so that the value does not
-// show up as `null` in JSON. Otherwise, return the provided slice.
-func clean_comments_slice(in_slice []string) []string {
-	if in_slice == nil {
-		return make([]string, 0)
+// Returns the import declarations of a file. `file_packages` maps the names of
+// all of the files in the request to their packages.
+func get_import_decls(
+	desc_file *desc_pb.FileDescriptorProto,
+	file_packages map[string]string,
+) []*docdata.ImportDecl {
+	imports := make([]*docdata.ImportDecl, 0, len(desc_file.Dependency))
+	for _, file_name := range desc_file.Dependency {
+		imports = append(imports, &docdata.ImportDecl{
+			Name:    file_name,
+			Package: file_packages[file_name],
+		})
+	}
+
+	for _, idx := range desc_file.PublicDependency {
+		if int(idx) < len(imports) {
+			imports[idx].Public = true
+		}
+	}
+
+	for _, idx := range desc_file.WeakDependency {
+		if int(idx) < len(imports) {
+			imports[idx].Weak = true
+		}
+	}
+
+	return imports
+}
+
+// Returns an option declaration for the given field number in
+// `google.protobuf.FileOptions`. The names of custom options are resolved later
+// on, once all of the extensions are known.
+func get_option_decl(field_num int32) *docdata.OptionDecl {
+	option_decl := &docdata.OptionDecl{FieldNumber: field_num}
+
+	file_opts_desc := new(desc_pb.FileOptions).ProtoReflect().Descriptor()
+	field_desc := file_opts_desc.Fields().ByNumber(
+		protoreflect.FieldNumber(field_num))
+	if field_desc != nil {
+		option_decl.Name = string(field_desc.Name())
+	} else {
+		option_decl.Custom = true
+	}
+
+	return option_decl
+}
+
+// Attaches each `extend` block to the extensions declared within it, based on
+// their source locations.
+func attach_extend_decls(
+	file_data *docdata.FileData,
+	extend_decls []*docdata.ExtendDecl,
+) {
+	for _, ext := range file_data.Extensions {
+		if ext.Source == nil {
+			continue
+		}
+
+		for _, extend_decl := range extend_decls {
+			if source_contains(extend_decl.Source, ext.Source) {
+				extend_decl.Extendee = ext.Extendee
+				ext.ExtendDecl = extend_decl
+				break
+			}
+		}
 	}
+}
 
-	cleaned := make([]string, 0, len(in_slice))
-	for _, comment := range in_slice {
-		cleaned = append(cleaned, strings.TrimSpace(comment))
+// Resolves the names of custom file options in option declarations. This needs
+// to run after the extension map is populated.
+func resolve_option_decl_names(
+	data *docdata.TemplateData,
+	all_file_descriptors []*desc_pb.FileDescriptorProto,
+) {
+	// Custom file options are usually defined in imported files, so the
+	// extensions are looked up in all of the files in the request.
+	ext_names := make(map[int32]string)
+	for _, desc_file := range all_file_descriptors {
+		for _, ext := range desc_file.Extension {
+			if ext.GetExtendee() != ".google.protobuf.FileOptions" {
+				continue
+			}
+			name := ext.GetName()
+			if desc_file.GetPackage() != "" {
+				name = desc_file.GetPackage() + "." + name
+			}
+			ext_names[ext.GetNumber()] = name
+		}
 	}
 
-	return cleaned
+	for _, file_data := range data.FileMap {
+		for _, option_decl := range file_data.OptionDecls {
+			if option_decl.Custom {
+				if name, ok := ext_names[option_decl.FieldNumber]; ok {
+					option_decl.Name = "(" + name + ")"
+				}
+			}
+		}
+	}
 }
 
-// Trim white space around comments.
-func clean_comments(
+// Sets the comment fields from a source code location. The comments are
+// normalized, and the original text is kept in `RawComments`.
+func set_comments(
+	comments *docdata.CommentData,
 	location *desc_pb.SourceCodeInfo_Location,
-) (leading_comments, trailing_comments, description string) {
-	leading_comments = strings.TrimSpace(location.GetLeadingComments())
-	trailing_comments = strings.TrimSpace(location.GetTrailingComments())
-	description = get_description(leading_comments, trailing_comments)
+) {
+	raw_detached := location.GetLeadingDetachedComments()
+	if raw_detached == nil {
+		raw_detached = make([]string, 0)
+	}
+	comments.RawComments = docdata.RawComments{
+		Leading:         location.GetLeadingComments(),
+		Trailing:        location.GetTrailingComments(),
+		LeadingDetached: raw_detached,
+	}
 
-	return
+	comments.LeadingComments = normalize_comment(location.GetLeadingComments())
+	comments.TrailingComments =
+		normalize_comment(location.GetTrailingComments())
+	comments.LeadingDetachedComments = make([]string, 0, len(raw_detached))
+	for _, comment := range raw_detached {
+		comments.LeadingDetachedComments =
+			append(comments.LeadingDetachedComments, normalize_comment(comment))
+	}
+
+	comments.Description = get_description(comments.LeadingComments,
+		comments.TrailingComments, DEFAULT_COMMENT_SEPARATOR)
 }
 
-func get_description(leading_comments, trailing_comments string) string {
+// Joins the leading and trailing comments with the given separator, if both
+// are non-empty.
+func get_description(
+	leading_comments, trailing_comments, separator string,
+) string {
 	desc := ""
 	if leading_comments != "" {
 		desc = leading_comments
@@ -531,7 +709,7 @@ func get_description(leading_comments, trailing_comments string) string {
 
 	if trailing_comments != "" {
 		if desc != "" {
-			desc += " "
+			desc += separator
 		}
 		desc += trailing_comments
 	}
@@ -543,19 +721,17 @@ func add_extension_comments(
 	loc_path []int32,
 	file_data *docdata.FileData,
 	location *desc_pb.SourceCodeInfo_Location,
+	source *docdata.SourceLocation,
 ) {
 	if len(loc_path) == 0 {
-		// Extension declaration. We can get a comment, but nothing to attach it
-		// to, so dropping it.
+		// The `extend` block itself is handled by the caller.
 		return
 	}
 
 	if len(loc_path) == 1 {
 		ext := file_data.Extensions[loc_path[0]]
-		ext.LeadingDetachedComments =
-			clean_comments_slice(location.LeadingDetachedComments)
-		ext.LeadingComments, ext.TrailingComments, ext.Description =
-			clean_comments(location)
+		ext.Source = source
+		set_comments(&ext.CommentData, location)
 		return
 	}
 }
@@ -564,13 +740,12 @@ func add_enum_comments(
 	loc_path []int32,
 	enum_data *docdata.EnumData,
 	location *desc_pb.SourceCodeInfo_Location,
+	source *docdata.SourceLocation,
 ) {
 	if len(loc_path) == 0 {
 		// Comments for the enum declaration itself.
-		enum_data.LeadingDetachedComments =
-			clean_comments_slice(location.LeadingDetachedComments)
-		enum_data.LeadingComments, enum_data.TrailingComments,
-			enum_data.Description = clean_comments(location)
+		enum_data.Source = source
+		set_comments(&enum_data.CommentData, location)
 		return
 	}
 
@@ -578,10 +753,8 @@ func add_enum_comments(
 		// Comments for an enum value.
 		if len(loc_path) == 2 {
 			enum_val := enum_data.Values[loc_path[1]]
-			enum_val.LeadingComments, enum_val.TrailingComments,
-				enum_val.Description = clean_comments(location)
-			enum_val.LeadingDetachedComments =
-				clean_comments_slice(location.LeadingDetachedComments)
+			enum_val.Source = source
+			set_comments(&enum_val.CommentData, location)
 		}
 	}
 
@@ -591,12 +764,11 @@ func add_service_comments(
 	loc_path []int32,
 	svc *docdata.ServiceData,
 	location *desc_pb.SourceCodeInfo_Location,
+	source *docdata.SourceLocation,
 ) {
 	if len(loc_path) == 2 {
-		svc.LeadingDetachedComments =
-			clean_comments_slice(location.LeadingDetachedComments)
-		svc.LeadingComments, svc.TrailingComments, svc.Description =
-			clean_comments(location)
+		svc.Source = source
+		set_comments(&svc.CommentData, location)
 		return
 	}
 
@@ -604,10 +776,8 @@ func add_service_comments(
 	if loc_path[2] == 2 {
 		if len(loc_path) == 4 {
 			method := svc.Methods[loc_path[3]]
-			method.LeadingDetachedComments =
-				clean_comments_slice(location.LeadingDetachedComments)
-			method.LeadingComments, method.TrailingComments,
-				method.Description = clean_comments(location)
+			method.Source = source
+			set_comments(&method.CommentData, location)
 		}
 
 	}
@@ -617,12 +787,11 @@ func add_msg_desc(
 	loc_path []int32,
 	msg *docdata.MessageData,
 	location *desc_pb.SourceCodeInfo_Location,
+	source *docdata.SourceLocation,
 ) {
 	if len(loc_path) == 0 {
-		msg.LeadingComments, msg.TrailingComments, msg.Description =
-			clean_comments(location)
-		msg.LeadingDetachedComments =
-			clean_comments_slice(location.LeadingDetachedComments)
+		msg.Source = source
+		set_comments(&msg.CommentData, location)
 		return
 	}
 
@@ -632,23 +801,21 @@ func add_msg_desc(
 		// Field comment.
 		field := msg.Fields[loc_path[1]]
 		if len(loc_path) == 2 {
-			field.LeadingComments, field.TrailingComments, field.Description =
-				clean_comments(location)
-			field.LeadingDetachedComments =
-				clean_comments_slice(location.LeadingDetachedComments)
+			field.Source = source
+			set_comments(&field.CommentData, location)
 		}
 	case 3:
 		// Nested messages
 		nested_msg := msg.NestedMessages[loc_path[1]]
-		add_msg_desc(loc_path[2:], nested_msg, location)
+		add_msg_desc(loc_path[2:], nested_msg, location, source)
 	case 4:
 		// Enum within a message.
 		enum_data := msg.Enums[loc_path[1]]
-		add_enum_comments(loc_path[2:], enum_data, location)
+		add_enum_comments(loc_path[2:], enum_data, location, source)
 	case 8:
 		// Oneof declaration.
 		oneof_decl := msg.OneofDecls[loc_path[1]]
-		add_oneof_comments(loc_path[2:], oneof_decl, location)
+		add_oneof_comments(loc_path[2:], oneof_decl, location, source)
 	}
 }
 
@@ -656,13 +823,12 @@ func add_oneof_comments(
 	loc_path []int32,
 	oneof_decl *docdata.OneOfData,
 	location *desc_pb.SourceCodeInfo_Location,
+	source *docdata.SourceLocation,
 ) {
 	if len(loc_path) == 0 {
 		// Comments for the oneof declaration.
-		oneof_decl.LeadingDetachedComments =
-			clean_comments_slice(location.LeadingDetachedComments)
-		oneof_decl.LeadingComments, oneof_decl.TrailingComments,
-			oneof_decl.Description = clean_comments(location)
+		oneof_decl.Source = source
+		set_comments(&oneof_decl.CommentData, location)
 		return
 	}
 }
@@ -715,8 +881,14 @@ func set_message_options(
 	}
 
 	this_msg.Options = &docdata.MessageOptions{
-		Deprecated: msg_opts.GetDeprecated(),
-		MapEntry:   msg_opts.GetMapEntry(),
+		MessageSetWireFormat:         msg_opts.GetMessageSetWireFormat(),
+		NoStandardDescriptorAccessor: msg_opts.GetNoStandardDescriptorAccessor(),
+		Deprecated:                   msg_opts.GetDeprecated(),
+		MapEntry:                     msg_opts.GetMapEntry(),
+		DeprecatedLegacyJsonFieldConflicts: msg_opts.
+			GetDeprecatedLegacyJsonFieldConflicts(),
+		Features:   get_features(msg_opts.GetFeatures()),
+		RawOptions: get_raw_options(msg_opts),
 	}
 }
 
@@ -726,9 +898,18 @@ func get_oneof_data(
 ) []*docdata.OneOfData {
 	oneofs := make([]*docdata.OneOfData, 0, len(desc_oneof_decls))
 	for _, oneof_decl := range desc_oneof_decls {
+		oneof_opts := oneof_decl.GetOptions()
+		if oneof_opts == nil {
+			oneof_opts = new(desc_pb.OneofOptions)
+		}
+
 		this_oneof := &docdata.OneOfData{
 			Name:     oneof_decl.GetName(),
 			FullName: namespace.QualifyName(oneof_decl.GetName()),
+			Options: &docdata.OneofOptions{
+				Features:   get_features(oneof_opts.GetFeatures()),
+				RawOptions: get_raw_options(oneof_opts),
+			},
 		}
 
 		oneofs = append(oneofs, this_oneof)
@@ -788,7 +969,10 @@ func set_enum_val_options(
 	}
 
 	this_enum_val.Options = &docdata.EnumValueOptions{
-		Deprecated: enum_val_opts.GetDeprecated(),
+		Deprecated:  enum_val_opts.GetDeprecated(),
+		DebugRedact: enum_val_opts.GetDebugRedact(),
+		Features:    get_features(enum_val_opts.GetFeatures()),
+		RawOptions:  get_raw_options(enum_val_opts),
 	}
 }
 
@@ -804,6 +988,10 @@ func set_enum_options(
 	this_enum.Options = &docdata.EnumOptions{
 		AllowAlias: enum_opts.GetAllowAlias(),
 		Deprecated: enum_opts.GetDeprecated(),
+		DeprecatedLegacyJsonFieldConflicts: enum_opts.
+			GetDeprecatedLegacyJsonFieldConflicts(),
+		Features:   get_features(enum_opts.GetFeatures()),
+		RawOptions: get_raw_options(enum_opts),
 	}
 }
 
@@ -843,6 +1031,15 @@ func get_field_data_from_desc(
 
 	this_field.DefaultValue = field.GetDefaultValue()
 
+	// The protobuf compiler fills in `json_name` for every field, so compare
+	// against the derived name to tell whether it was set explicitly.
+	default_json_name := get_default_json_name(this_field.Name)
+	this_field.JSONName = default_json_name
+	if field.JsonName != nil {
+		this_field.JSONName = field.GetJsonName()
+		this_field.JSONNameExplicit = this_field.JSONName != default_json_name
+	}
+
 	this_field.OneofIndex = field.GetOneofIndex()
 	if field.OneofIndex != nil {
 		this_field.InOneof = true
@@ -851,20 +1048,39 @@ func get_field_data_from_desc(
 		this_field.OneofFullName = oneof_data.FullName
 	}
 
+	this_field.Options = get_field_options(field)
+
+	return this_field
+}
+
+func get_field_options(
+	field *desc_pb.FieldDescriptorProto,
+) *docdata.FieldOptions {
 	fopts := field.Options
 	if field.Options == nil {
 		fopts = new(desc_pb.FieldOptions)
 	}
 
-	this_field.Options = &docdata.FieldOptions{
-		CType:      fopts.GetCtype(),
-		Packed:     fopts.GetPacked(),
-		JSType:     fopts.GetJstype(),
-		Lazy:       fopts.GetLazy(),
-		Deprecated: fopts.GetDeprecated(),
+	field_opts := &docdata.FieldOptions{
+		CType:           fopts.GetCtype(),
+		Packed:          fopts.GetPacked(),
+		JSType:          fopts.GetJstype(),
+		Lazy:            fopts.GetLazy(),
+		UnverifiedLazy:  fopts.GetUnverifiedLazy(),
+		Deprecated:      fopts.GetDeprecated(),
+		Weak:            fopts.GetWeak(),
+		DebugRedact:     fopts.GetDebugRedact(),
+		Retention:       fopts.GetRetention(),
+		Targets:         fopts.GetTargets(),
+		EditionDefaults: get_edition_defaults(fopts.GetEditionDefaults()),
+		Features:        get_features(fopts.GetFeatures()),
+		RawOptions:      get_raw_options(fopts),
+	}
+	if field_opts.Targets == nil {
+		field_opts.Targets = make([]desc_pb.FieldOptions_OptionTargetType, 0)
 	}
 
-	return this_field
+	return field_opts
 }
 
 func field_type_enum_to_string(
@@ -0,0 +1,311 @@
+package docgen
+
+// This file contains the code to extract code examples from comments, and to
+// check JSON examples against the messages they belong to.
+
+// BSD 2-Clause License
+//
+// Copyright (c) 2023 Don Owens <don@regexguy.com>.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// * Redistributions of source code must retain the above copyright notice,
+//   this list of conditions and the following disclaimer.
+//
+// * Redistributions in binary form must reproduce the above copyright notice,
+//   this list of conditions and the following disclaimer in the documentation
+//   and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+import (
+	// Built-in/core modules.
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	// Third-party modules.
+	gm_ast "github.com/yuin/goldmark/ast"
+	gm_text "github.com/yuin/goldmark/text"
+
+	// Generated code.
+	// First-party modules.
+	docdata "github.com/cuberat/protoc-gen-docjson/internal/docdata"
+)
+
+const EXAMPLE_TAG = "example"
+
+// Sets the `Examples` field from the fenced code blocks in the description and
+// the `@example` tags.
+func extract_examples(comments *docdata.CommentData) {
+	comments.Examples = make([]*docdata.Example, 0)
+
+	source := []byte(comments.Description)
+	doc := markdown_converter.Parser().Parse(gm_text.NewReader(source))
+	gm_ast.Walk(doc,
+		func(node gm_ast.Node, entering bool) (gm_ast.WalkStatus, error) {
+			code_block, ok := node.(*gm_ast.FencedCodeBlock)
+			if !ok || !entering {
+				return gm_ast.WalkContinue, nil
+			}
+
+			info := ""
+			if code_block.Info != nil {
+				info = string(code_block.Info.Segment.Value(source))
+			}
+			example := new_example(info, get_markdown_lines(code_block, source))
+			example.Origin = "code_block"
+			comments.Examples = append(comments.Examples, example)
+
+			return gm_ast.WalkSkipChildren, nil
+		},
+	)
+
+	for _, tag_val := range comments.Tags[EXAMPLE_TAG] {
+		example := get_tag_example(tag_val)
+		example.Origin = "tag"
+		comments.Examples = append(comments.Examples, example)
+	}
+}
+
+// Builds an example from an `@example` tag value. The value can be a fenced
+// code block, or the example itself. If the example spans multiple lines and
+// the first line doesn't start a JSON value, the first line is the title.
+// E.g.,
+//
+//	@example Create a widget:
+//	{"name": "foo"}
+func get_tag_example(tag_val string) *docdata.Example {
+	title := ""
+	body := tag_val
+	first_line, rest, multi_line := strings.Cut(tag_val, "\n")
+	if multi_line && !strings.HasPrefix(first_line, "{") &&
+		!strings.HasPrefix(first_line, "[") &&
+		!strings.HasPrefix(first_line, "```") {
+		title = strings.TrimSuffix(strings.TrimSpace(first_line), ":")
+		body = rest
+	}
+
+	body = strings.TrimSpace(body)
+	if strings.HasPrefix(body, "```") {
+		info, fenced, _ := strings.Cut(body, "\n")
+		fenced = strings.TrimSuffix(strings.TrimSpace(fenced), "```")
+		example := new_example(strings.TrimPrefix(info, "```"),
+			strings.TrimRight(fenced, "\n"))
+		if example.Title == "" {
+			example.Title = title
+		}
+		return example
+	}
+
+	example := new_example("", body)
+	example.Title = title
+
+	return example
+}
+
+// Builds an example from the info string of a fenced code block (e.g.,
+// `json Create a widget` or `json title="Create a widget"`) and the body. If
+// no language is given, and the body is valid JSON, the language is "json".
+func new_example(info, body string) *docdata.Example {
+	language, title, _ := strings.Cut(strings.TrimSpace(info), " ")
+	title = strings.TrimSpace(title)
+	if strings.HasPrefix(title, "title=") {
+		title = strings.TrimPrefix(title, "title=")
+		if unquoted, err := strconv.Unquote(title); err == nil {
+			title = unquoted
+		}
+	}
+
+	if language == "" && json.Valid([]byte(body)) {
+		language = "json"
+	}
+
+	return &docdata.Example{
+		Language: language,
+		Title:    title,
+		Body:     body,
+	}
+}
+
+// Checks the JSON examples for each message against the message's fields,
+// adding a warning for examples that aren't valid JSON and for fields in the
+// examples that aren't defined in the message. Fields can be given by their
+// protobuf name or JSON name, as with the protobuf JSON mapping.
+func check_examples(data *docdata.TemplateData) {
+	for_each_comment_data(data, func(elem *comment_element) {
+		check_element_examples(data, elem)
+	})
+}
+
+// Checks the JSON examples for a single element, if it is a message.
+func check_element_examples(
+	data *docdata.TemplateData,
+	elem *comment_element,
+) {
+	if elem.kind != "message" {
+		return
+	}
+	msg := data.MessageMap[elem.full_name]
+	if msg == nil {
+		return
+	}
+
+	for i, example := range elem.comments.Examples {
+		if example.Language != "json" {
+			continue
+		}
+
+		label := get_example_label(i, example)
+
+		var value any
+		if err := json.Unmarshal([]byte(example.Body), &value); err != nil {
+			add_warning(data, "invalid_example", elem,
+				"%s is not valid JSON: %s", label, err)
+			continue
+		}
+
+		problems := make([]string, 0)
+		problems = check_json_message(data, msg, value, "", problems)
+		for _, problem := range problems {
+			add_warning(data, "stale_example", elem, "%s: %s", label,
+				problem)
+		}
+	}
+}
+
+func get_example_label(idx int, example *docdata.Example) string {
+	if example.Title != "" {
+		return fmt.Sprintf("example %q", example.Title)
+	}
+
+	return fmt.Sprintf("example %d", idx+1)
+}
+
+// Checks a JSON value against a message, returning a list of problems. The
+// path is the location of the value within the example, e.g., "items[0]".
+func check_json_message(
+	data *docdata.TemplateData,
+	msg *docdata.MessageData,
+	value any,
+	path string,
+	problems []string,
+) []string {
+	if WELL_KNOWN_JSON_TYPES[msg.FullName] != nil {
+		// These have special representations in JSON.
+		return problems
+	}
+
+	obj, ok := value.(map[string]any)
+	if !ok {
+		if value == nil {
+			return problems
+		}
+		return append(problems,
+			fmt.Sprintf("expected an object for %s at %s", msg.FullName,
+				get_json_path(path)))
+	}
+
+	fields := make(map[string]*docdata.FieldData, 2*len(msg.Fields))
+	for _, field := range msg.Fields {
+		fields[field.Name] = field
+		fields[field.JSONName] = field
+	}
+
+	for _, key := range get_sorted_map_keys(obj) {
+		field_path := join_json_path(path, key)
+		field := fields[key]
+		if field == nil {
+			problems = append(problems,
+				fmt.Sprintf("unknown field %s in %s", field_path,
+					msg.FullName))
+			continue
+		}
+
+		problems = check_json_field(data, field, obj[key], field_path,
+			problems)
+	}
+
+	return problems
+}
+
+// Checks the value of a message-typed field, including repeated fields and
+// maps with message values.
+func check_json_field(
+	data *docdata.TemplateData,
+	field *docdata.FieldData,
+	value any,
+	path string,
+	problems []string,
+) []string {
+	if field.Kind != "message" {
+		return problems
+	}
+
+	if entry := data.GetMapEntry(field); entry != nil {
+		entries, ok := value.(map[string]any)
+		if !ok {
+			return problems
+		}
+		val_field := entry.Fields[1]
+		for _, key := range get_sorted_map_keys(entries) {
+			problems = check_json_field(data, val_field, entries[key],
+				path+"."+key, problems)
+		}
+		return problems
+	}
+
+	field_msg := data.MessageMap[field.FullTypeName]
+	if field_msg == nil {
+		return problems
+	}
+
+	if items, ok := value.([]any); ok && field.Label == "repeated" {
+		for i, item := range items {
+			problems = check_json_message(data, field_msg, item,
+				fmt.Sprintf("%s[%d]", path, i), problems)
+		}
+		return problems
+	}
+
+	return check_json_message(data, field_msg, value, path, problems)
+}
+
+func get_sorted_map_keys(in_map map[string]any) []string {
+	keys := make([]string, 0, len(in_map))
+	for key := range in_map {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func join_json_path(path, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
+
+func get_json_path(path string) string {
+	if path == "" {
+		return "top level"
+	}
+
+	return path
+}
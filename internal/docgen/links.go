@@ -0,0 +1,308 @@
+package docgen
+
+// This file contains the code to resolve references to other elements in
+// comments, e.g., `[TesterRequest]` or `{@link pkg.Other.field}`.
+
+// BSD 2-Clause License
+//
+// Copyright (c) 2023 Don Owens <don@regexguy.com>.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// * Redistributions of source code must retain the above copyright notice,
+//   this list of conditions and the following disclaimer.
+//
+// * Redistributions in binary form must reproduce the above copyright notice,
+//   this list of conditions and the following disclaimer in the documentation
+//   and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+import (
+	// Built-in/core modules.
+	"regexp"
+	"strings"
+
+	// Generated code.
+	// First-party modules.
+	docdata "github.com/cuberat/protoc-gen-docjson/internal/docdata"
+)
+
+const proto_ident = `[A-Za-z_][A-Za-z0-9_]*`
+const proto_ref = `\.?` + proto_ident + `(?:\.` + proto_ident + `)*`
+
+var (
+	// E.g., `[TesterRequest]`. Markdown links (`[text](url)` and
+	// `[text][ref]`) are skipped by the caller.
+	bracket_ref_re = regexp.MustCompile(`\[(` + proto_ref + `)\]`)
+
+	// E.g., `{@link Foo}`.
+	link_tag_ref_re = regexp.MustCompile(`\{@link\s+(` + proto_ref + `)\s*\}`)
+
+	// The whole value of a `@see` tag.
+	see_ref_re = regexp.MustCompile(`^(` + proto_ref + `)$`)
+)
+
+// Resolves references to other elements in the description and `@see` tags
+// of each element, using protobuf scoping rules relative to the element. The
+// resolved references are added to the `Links` of the element, and a warning
+// is added for each reference that can't be resolved.
+func resolve_comment_links(data *docdata.TemplateData) {
+	symbols := build_symbol_table(data)
+
+	for_each_comment_data(data, func(elem *comment_element) {
+		resolve_element_links(data, symbols, elem)
+	})
+}
+
+// Sets the `Links` for a single element.
+func resolve_element_links(
+	data *docdata.TemplateData,
+	symbols map[string]*symbol,
+	elem *comment_element,
+) {
+	comments := elem.comments
+	comments.Links = make([]*docdata.CommentLink, 0)
+	seen := make(map[string]bool)
+
+	add_link := func(text, name string) {
+		if seen[text] {
+			return
+		}
+		seen[text] = true
+
+		target, kind := resolve_symbol(symbols, name, elem.scope)
+		if target == "" {
+			add_warning(data, "unresolved_link", elem,
+				"couldn't resolve reference %s", text)
+			return
+		}
+
+		comments.Links = append(comments.Links, &docdata.CommentLink{
+			Text:   text,
+			Target: target,
+			Kind:   kind,
+		})
+	}
+
+	// References in code aren't links, so matching is done on a copy of the
+	// description with the code masked out.
+	desc := comments.Description
+	masked := mask_code(desc)
+	for _, match := range bracket_ref_re.FindAllStringSubmatchIndex(masked, -1) {
+		if is_markdown_link(desc, match[0], match[1]) {
+			continue
+		}
+		add_link(desc[match[0]:match[1]], desc[match[2]:match[3]])
+	}
+
+	for _, match := range link_tag_ref_re.FindAllStringSubmatchIndex(masked, -1) {
+		add_link(desc[match[0]:match[1]], desc[match[2]:match[3]])
+	}
+
+	for _, see_val := range comments.Tags["see"] {
+		if match := see_ref_re.FindStringSubmatch(see_val); match != nil {
+			add_link(match[0], match[1])
+		}
+	}
+}
+
+// Returns the text with fenced code blocks (delimited by lines starting with
+// three backticks or tildes) and inline code spans replaced by spaces, so that
+// the positions of the rest of the text are unchanged.
+func mask_code(text string) string {
+	masked := []byte(text)
+	blank := func(start, end int) {
+		for i := start; i < end; i++ {
+			if masked[i] != '\n' {
+				masked[i] = ' '
+			}
+		}
+	}
+
+	// Fenced code blocks, including the fence lines.
+	fence := ""
+	offset := 0
+	for _, line := range strings.SplitAfter(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case fence != "":
+			blank(offset, offset+len(line))
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+		case strings.HasPrefix(trimmed, "```"), strings.HasPrefix(trimmed, "~~~"):
+			fence = trimmed[:3]
+			blank(offset, offset+len(line))
+		}
+		offset += len(line)
+	}
+
+	// Inline code spans: a run of backticks up to the next run of the same
+	// length.
+	for i := 0; i < len(masked); {
+		if masked[i] != '`' {
+			i++
+			continue
+		}
+		run_end := skip_backticks(masked, i)
+
+		span_end := -1
+		for j := run_end; j < len(masked); j++ {
+			if masked[j] != '`' {
+				continue
+			}
+			close_end := skip_backticks(masked, j)
+			if close_end-j == run_end-i {
+				span_end = close_end
+				break
+			}
+			j = close_end
+		}
+
+		if span_end < 0 {
+			i = run_end
+			continue
+		}
+		blank(i, span_end)
+		i = span_end
+	}
+
+	return string(masked)
+}
+
+// Returns the index after the run of backticks starting at `start`.
+func skip_backticks(text []byte, start int) int {
+	end := start
+	for end < len(text) && text[end] == '`' {
+		end++
+	}
+
+	return end
+}
+
+// Returns true if the bracketed text at `desc[start:end]` is part of a
+// Markdown link (e.g., `[text](url)`, `[text][ref]`, or a `[ref]: url`
+// definition), or an index expression like `list[idx]`, rather than a
+// reference.
+func is_markdown_link(desc string, start, end int) bool {
+	if end < len(desc) && strings.ContainsRune("([:", rune(desc[end])) {
+		return true
+	}
+
+	if start > 0 {
+		prev := desc[start-1]
+		if prev == ']' || prev == '_' || prev == '.' ||
+			(prev >= '0' && prev <= '9') ||
+			(prev >= 'A' && prev <= 'Z') || (prev >= 'a' && prev <= 'z') {
+			return true
+		}
+	}
+
+	return false
+}
+
+// An element that can be referenced in comments.
+type symbol struct {
+	full_name string
+	kind      string
+}
+
+// Returns a map of the fully-qualified name of every element that can be
+// referenced in comments to the element.
+func build_symbol_table(data *docdata.TemplateData) map[string]*symbol {
+	symbols := make(map[string]*symbol)
+	add_symbol := func(full_name, kind string) {
+		symbols[full_name] = &symbol{full_name: full_name, kind: kind}
+	}
+
+	for name, msg := range data.MessageMap {
+		add_symbol(name, "message")
+		for _, field := range msg.Fields {
+			add_symbol(field.FullName, "field")
+		}
+		for _, oneof := range msg.OneofDecls {
+			add_symbol(oneof.FullName, "oneof")
+		}
+	}
+
+	for name, enum_data := range data.EnumMap {
+		add_symbol(name, "enum")
+		scope, _ := split_full_name(name)
+		for _, enum_val := range enum_data.Values {
+			// Enum values are named through their enum, so that the enum can
+			// be found from the name. In the protobuf language, they are
+			// siblings of their enum, so that name refers to the same value.
+			val_name := qualify_name(name, enum_val.Name)
+			add_symbol(val_name, "enum_value")
+			symbols[qualify_name(scope, enum_val.Name)] = symbols[val_name]
+		}
+	}
+
+	for name, svc := range data.ServiceMap {
+		add_symbol(name, "service")
+		for _, method := range svc.Methods {
+			add_symbol(method.FullName, "method")
+		}
+	}
+
+	for name := range data.ExtensionMap {
+		add_symbol(name, "extension")
+	}
+
+	for name := range data.PackageMap {
+		if name != "" {
+			add_symbol(name, "package")
+		}
+	}
+
+	return symbols
+}
+
+// Resolves a name relative to `scope` the way the protobuf compiler resolves
+// type names: the name is looked up in the scope, then in each enclosing
+// scope up to the top level. A leading "." means the name is already
+// fully-qualified. Returns the fully-qualified name and kind of the target, or
+// empty strings if it couldn't be found.
+func resolve_symbol(
+	symbols map[string]*symbol,
+	name, scope string,
+) (full_name, kind string) {
+	if strings.HasPrefix(name, ".") {
+		if sym := symbols[name[1:]]; sym != nil {
+			return sym.full_name, sym.kind
+		}
+		return "", ""
+	}
+
+	for {
+		if sym := symbols[qualify_name(scope, name)]; sym != nil {
+			return sym.full_name, sym.kind
+		}
+		if scope == "" {
+			return "", ""
+		}
+		scope, _ = split_full_name(scope)
+	}
+}
+
+// Splits a fully-qualified name into its enclosing scope and short name.
+func split_full_name(full_name string) (scope, name string) {
+	dot_idx := strings.LastIndexByte(full_name, '.')
+	if dot_idx < 0 {
+		return "", full_name
+	}
+
+	return full_name[:dot_idx], full_name[dot_idx+1:]
+}
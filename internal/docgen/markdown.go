@@ -0,0 +1,217 @@
+package docgen
+
+// This file contains the code to render Markdown descriptions to HTML and to
+// a list of structured blocks.
+
+// BSD 2-Clause License
+//
+// Copyright (c) 2023 Don Owens <don@regexguy.com>.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// * Redistributions of source code must retain the above copyright notice,
+//   this list of conditions and the following disclaimer.
+//
+// * Redistributions in binary form must reproduce the above copyright notice,
+//   this list of conditions and the following disclaimer in the documentation
+//   and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+import (
+	// Built-in/core modules.
+	"bytes"
+	"strings"
+
+	// Third-party modules.
+	log "github.com/sirupsen/logrus"
+	goldmark "github.com/yuin/goldmark"
+	gm_ast "github.com/yuin/goldmark/ast"
+	gm_ext "github.com/yuin/goldmark/extension"
+	gm_ext_ast "github.com/yuin/goldmark/extension/ast"
+	gm_text "github.com/yuin/goldmark/text"
+
+	// Generated code.
+	// First-party modules.
+	docdata "github.com/cuberat/protoc-gen-docjson/internal/docdata"
+)
+
+// The Markdown converter. Raw HTML in comments is omitted from the output, and
+// links with dangerous URLs (e.g., `javascript:`) are dropped, since this is
+// the default for goldmark unless `html.WithUnsafe()` is used.
+var markdown_converter = goldmark.New(
+	goldmark.WithExtensions(gm_ext.GFM),
+)
+
+// Sets the `DescriptionHTML` and `DescriptionBlocks` fields from the
+// description, which is treated as CommonMark (plus GitHub extensions such as
+// tables).
+func render_markdown(comments *docdata.CommentData) {
+	comments.DescriptionHTML = ""
+	comments.DescriptionBlocks = make([]*docdata.DescriptionBlock, 0)
+	if comments.Description == "" {
+		return
+	}
+
+	source := []byte(comments.Description)
+	doc := markdown_converter.Parser().Parse(gm_text.NewReader(source))
+
+	comments.DescriptionHTML = render_markdown_node(doc, source)
+
+	for node := doc.FirstChild(); node != nil; node = node.NextSibling() {
+		block := get_description_block(node, source)
+		if block == nil {
+			continue
+		}
+		block.HTML = render_markdown_node(node, source)
+		comments.DescriptionBlocks = append(comments.DescriptionBlocks, block)
+	}
+}
+
+func render_markdown_node(node gm_ast.Node, source []byte) string {
+	var buf bytes.Buffer
+	err := markdown_converter.Renderer().Render(&buf, source, node)
+	if err != nil {
+		log.Errorf("couldn't render Markdown: %s", err)
+		return ""
+	}
+
+	return strings.TrimSpace(buf.String())
+}
+
+// Returns the structured version of a top-level Markdown block, or nil for
+// blocks that are not represented (raw HTML).
+func get_description_block(
+	node gm_ast.Node,
+	source []byte,
+) *docdata.DescriptionBlock {
+	switch node := node.(type) {
+	case *gm_ast.Paragraph, *gm_ast.TextBlock:
+		return &docdata.DescriptionBlock{
+			Type: "paragraph",
+			Text: get_markdown_text(node, source),
+		}
+
+	case *gm_ast.Heading:
+		return &docdata.DescriptionBlock{
+			Type:  "heading",
+			Level: node.Level,
+			Text:  get_markdown_text(node, source),
+		}
+
+	case *gm_ast.List:
+		block := &docdata.DescriptionBlock{
+			Type:    "list",
+			Ordered: node.IsOrdered(),
+			Items:   make([]string, 0, node.ChildCount()),
+		}
+		for item := node.FirstChild(); item != nil; item = item.NextSibling() {
+			block.Items = append(block.Items, get_markdown_text(item, source))
+		}
+		return block
+
+	case *gm_ast.FencedCodeBlock:
+		return &docdata.DescriptionBlock{
+			Type:     "code",
+			Language: string(node.Language(source)),
+			Code:     get_markdown_lines(node, source),
+		}
+
+	case *gm_ast.CodeBlock:
+		return &docdata.DescriptionBlock{
+			Type: "code",
+			Code: get_markdown_lines(node, source),
+		}
+
+	case *gm_ast.Blockquote:
+		return &docdata.DescriptionBlock{
+			Type: "quote",
+			Text: get_markdown_text(node, source),
+		}
+
+	case *gm_ast.ThematicBreak:
+		return &docdata.DescriptionBlock{Type: "rule"}
+
+	case *gm_ext_ast.Table:
+		block := &docdata.DescriptionBlock{
+			Type:   "table",
+			Header: make([]string, 0),
+			Rows:   make([][]string, 0),
+		}
+		for row := node.FirstChild(); row != nil; row = row.NextSibling() {
+			cells := make([]string, 0, row.ChildCount())
+			for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+				cells = append(cells, get_markdown_text(cell, source))
+			}
+			if _, ok := row.(*gm_ext_ast.TableHeader); ok {
+				block.Header = cells
+			} else {
+				block.Rows = append(block.Rows, cells)
+			}
+		}
+		return block
+	}
+
+	return nil
+}
+
+// Returns the plain text of a node, without any inline markup. Line breaks
+// within a paragraph become spaces, and separate blocks (e.g., paragraphs in a
+// quote) are separated by a blank line.
+func get_markdown_text(node gm_ast.Node, source []byte) string {
+	var buf strings.Builder
+
+	gm_ast.Walk(node,
+		func(child gm_ast.Node, entering bool) (gm_ast.WalkStatus, error) {
+			if !entering {
+				return gm_ast.WalkContinue, nil
+			}
+
+			if child != node && child.Type() == gm_ast.TypeBlock &&
+				buf.Len() > 0 {
+				buf.WriteString("\n\n")
+			}
+
+			switch child := child.(type) {
+			case *gm_ast.Text:
+				buf.Write(child.Segment.Value(source))
+				if child.SoftLineBreak() || child.HardLineBreak() {
+					buf.WriteString(" ")
+				}
+			case *gm_ast.String:
+				buf.Write(child.Value)
+			case *gm_ast.AutoLink:
+				buf.Write(child.Label(source))
+			case *gm_ast.RawHTML:
+				return gm_ast.WalkSkipChildren, nil
+			}
+
+			return gm_ast.WalkContinue, nil
+		},
+	)
+
+	return strings.TrimSpace(buf.String())
+}
+
+// Returns the raw lines of a code block.
+func get_markdown_lines(node gm_ast.Node, source []byte) string {
+	var buf bytes.Buffer
+	lines := node.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		buf.Write(line.Value(source))
+	}
+
+	return strings.TrimRight(buf.String(), "\n")
+}
@@ -0,0 +1,213 @@
+package docgen
+
+// This file contains the code to convert option messages from the descriptor
+// protobuf into generic maps, so that options not modeled explicitly in
+// `docdata` (e.g., options added in newer protobuf releases) still show up in
+// the output.
+
+// BSD 2-Clause License
+//
+// Copyright (c) 2023 Don Owens <don@regexguy.com>.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// * Redistributions of source code must retain the above copyright notice,
+//   this list of conditions and the following disclaimer.
+//
+// * Redistributions in binary form must reproduce the above copyright notice,
+//   this list of conditions and the following disclaimer in the documentation
+//   and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+import (
+	// Built-in/core modules.
+	"strconv"
+
+	// Third-party modules.
+	log "github.com/sirupsen/logrus"
+	protowire "google.golang.org/protobuf/encoding/protowire"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	desc_pb "google.golang.org/protobuf/types/descriptorpb"
+
+	// Generated code.
+	// First-party modules.
+	docdata "github.com/cuberat/protoc-gen-docjson/internal/docdata"
+)
+
+// Returns a map of all options explicitly set in the given options message.
+// Standard options are keyed by their field name, and extensions known to the
+// plugin by their fully-qualified name in parentheses. Fields unknown to the
+// plugin, including custom options, are keyed by their field number.
+func get_raw_options(opts protoreflect.ProtoMessage) map[string]any {
+	if opts == nil {
+		return make(map[string]any)
+	}
+
+	raw_opts := message_to_map(opts.ProtoReflect())
+
+	// This is only used by the parser, and never shows up in the descriptors
+	// sent to plugins.
+	delete(raw_opts, "uninterpreted_option")
+
+	return raw_opts
+}
+
+// Returns the features set on an element, keyed by feature name, or an empty
+// map if there are none.
+func get_features(features *desc_pb.FeatureSet) map[string]any {
+	if features == nil {
+		return make(map[string]any)
+	}
+
+	return message_to_map(features.ProtoReflect())
+}
+
+func get_edition_defaults(
+	defaults []*desc_pb.FieldOptions_EditionDefault,
+) []*docdata.FieldEditionDefault {
+	edition_defaults := make([]*docdata.FieldEditionDefault, 0, len(defaults))
+	for _, edition_default := range defaults {
+		edition_defaults = append(edition_defaults,
+			&docdata.FieldEditionDefault{
+				Edition: edition_default.GetEdition(),
+				Value:   edition_default.GetValue(),
+			})
+	}
+
+	return edition_defaults
+}
+
+func message_to_map(msg protoreflect.Message) map[string]any {
+	data := make(map[string]any)
+	if !msg.IsValid() {
+		return data
+	}
+
+	msg.Range(
+		func(fd protoreflect.FieldDescriptor, val protoreflect.Value) bool {
+			name := string(fd.Name())
+			if fd.IsExtension() {
+				name = "(" + string(fd.FullName()) + ")"
+			}
+			data[name] = field_value_to_any(fd, val)
+			return true
+		},
+	)
+
+	add_unknown_fields(data, msg.GetUnknown())
+
+	return data
+}
+
+func field_value_to_any(
+	fd protoreflect.FieldDescriptor,
+	val protoreflect.Value,
+) any {
+	switch {
+	case fd.IsList():
+		list := val.List()
+		items := make([]any, 0, list.Len())
+		for i := 0; i < list.Len(); i++ {
+			items = append(items, single_value_to_any(fd, list.Get(i)))
+		}
+		return items
+
+	case fd.IsMap():
+		items := make(map[string]any)
+		val.Map().Range(
+			func(key protoreflect.MapKey, map_val protoreflect.Value) bool {
+				items[key.String()] =
+					single_value_to_any(fd.MapValue(), map_val)
+				return true
+			},
+		)
+		return items
+	}
+
+	return single_value_to_any(fd, val)
+}
+
+func single_value_to_any(
+	fd protoreflect.FieldDescriptor,
+	val protoreflect.Value,
+) any {
+	switch fd.Kind() {
+	case protoreflect.EnumKind:
+		enum_val := fd.Enum().Values().ByNumber(val.Enum())
+		if enum_val == nil {
+			return int32(val.Enum())
+		}
+		return string(enum_val.Name())
+
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return message_to_map(val.Message())
+	}
+
+	return val.Interface()
+}
+
+// Adds fields that could not be decoded (because the plugin does not know
+// their definition) to the provided map, keyed by field number. Varints and
+// fixed-size values are decoded as unsigned integers. Length-delimited values
+// are left as bytes.
+func add_unknown_fields(data map[string]any, raw []byte) {
+	for len(raw) > 0 {
+		field_num, wire_type, tag_len := protowire.ConsumeTag(raw)
+		if tag_len < 0 {
+			log.Errorf("couldn't parse unknown option field: %s",
+				protowire.ParseError(tag_len))
+			return
+		}
+		raw = raw[tag_len:]
+
+		var (
+			val     any
+			val_len int
+		)
+
+		switch wire_type {
+		case protowire.VarintType:
+			val, val_len = protowire.ConsumeVarint(raw)
+		case protowire.Fixed32Type:
+			var num uint32
+			num, val_len = protowire.ConsumeFixed32(raw)
+			val = num
+		case protowire.Fixed64Type:
+			val, val_len = protowire.ConsumeFixed64(raw)
+		case protowire.BytesType:
+			val, val_len = protowire.ConsumeBytes(raw)
+		default:
+			val_len = protowire.ConsumeFieldValue(field_num, wire_type, raw)
+			val = nil
+		}
+
+		if val_len < 0 {
+			log.Errorf("couldn't parse unknown option field %d: %s", field_num,
+				protowire.ParseError(val_len))
+			return
+		}
+		raw = raw[val_len:]
+
+		key := strconv.Itoa(int(field_num))
+		switch existing := data[key].(type) {
+		case nil:
+			data[key] = val
+		case []any:
+			data[key] = append(existing, val)
+		default:
+			data[key] = []any{existing, val}
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package docgen
+
+// This file contains the code to merge description overlays from external
+// YAML files into the template data, e.g., to document third-party protobuf
+// specifications that can't be edited.
+
+// BSD 2-Clause License
+//
+// Copyright (c) 2023 Don Owens <don@regexguy.com>.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// * Redistributions of source code must retain the above copyright notice,
+//   this list of conditions and the following disclaimer.
+//
+// * Redistributions in binary form must reproduce the above copyright notice,
+//   this list of conditions and the following disclaimer in the documentation
+//   and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+import (
+	// Built-in/core modules.
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	// Third-party modules.
+	log "github.com/sirupsen/logrus"
+	yaml "gopkg.in/yaml.v3"
+
+	// Generated code.
+	// First-party modules.
+	docdata "github.com/cuberat/protoc-gen-docjson/internal/docdata"
+)
+
+// Contents of an overlay file.
+type overlay_file struct {
+	// Overlay entries, keyed by the fully-qualified name of the element.
+	Elements map[string]*overlay_entry `yaml:"elements"`
+}
+
+// Changes to make to a single element.
+type overlay_entry struct {
+	// Replaces the description.
+	Description *string `yaml:"description"`
+
+	// Appended to the description, separated by a blank line.
+	AppendDescription string `yaml:"append_description"`
+
+	// Replaces the values of the listed doc tags.
+	Tags map[string][]string `yaml:"tags"`
+
+	// Added to the examples from the comments.
+	Examples []*overlay_example `yaml:"examples"`
+
+	// Hides (or shows) the element, regardless of its tags and options.
+	Hidden *bool `yaml:"hidden"`
+}
+
+type overlay_example struct {
+	Language string `yaml:"language"`
+	Title    string `yaml:"title"`
+	Body     string `yaml:"body"`
+}
+
+// Warnings that are derived from the comments, and so have to be recomputed
+// when an overlay changes them.
+var comment_warning_codes = map[string]bool{
+	"unresolved_link": true,
+	"invalid_example": true,
+	"stale_example":   true,
+}
+
+// Merges the overlay files given by the `overlay` plugin option into the
+// template data. Overlay entries that don't match any element are reported as
+// warnings.
+func ApplyOverlays(data *docdata.TemplateData, conf *docdata.Config) error {
+	if len(conf.PluginOpts.Overlays) == 0 {
+		return nil
+	}
+
+	elements := make(map[string]*comment_element)
+	for_each_comment_data(data, func(elem *comment_element) {
+		if elem.full_name != "" {
+			elements[elem.full_name] = elem
+		}
+	})
+
+	proc := new_comment_processor(conf)
+	symbols := build_symbol_table(data)
+
+	for _, overlay_path := range conf.PluginOpts.Overlays {
+		overlay, err := read_overlay_file(overlay_path)
+		if err != nil {
+			return err
+		}
+
+		names := make([]string, 0, len(overlay.Elements))
+		for name := range overlay.Elements {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			full_name := strings.TrimPrefix(name, ".")
+			elem, ok := elements[full_name]
+			if !ok {
+				add_warning(data, "unknown_overlay_element",
+					&comment_element{full_name: name, file: overlay_path},
+					"overlay entry doesn't match any element")
+				continue
+			}
+
+			log.Debugf("applying overlay from %s to %s", overlay_path,
+				full_name)
+
+			entry := overlay.Elements[name]
+			if entry == nil {
+				continue
+			}
+			apply_overlay_entry(data, proc, symbols, elem, entry)
+		}
+	}
+
+	return nil
+}
+
+func read_overlay_file(overlay_path string) (*overlay_file, error) {
+	content, err := os.ReadFile(overlay_path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read overlay file: %w", err)
+	}
+
+	overlay := new(overlay_file)
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(overlay); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("couldn't parse overlay file %s: %w",
+			overlay_path, err)
+	}
+
+	return overlay, nil
+}
+
+func apply_overlay_entry(
+	data *docdata.TemplateData,
+	proc *comment_processor,
+	symbols map[string]*symbol,
+	elem *comment_element,
+	entry *overlay_entry,
+) {
+	comments := elem.comments
+	expand := func(text string) string {
+		return expand_vars(text, proc.vars, func(name string) {
+			add_warning(data, "undefined_variable", elem,
+				"variable ${%s} in overlay is not defined", name)
+		})
+	}
+
+	if entry.Description != nil {
+		comments.Description = strings.TrimSpace(expand(*entry.Description))
+	}
+	append_desc := strings.TrimSpace(expand(entry.AppendDescription))
+	if append_desc != "" {
+		comments.Description =
+			get_description(comments.Description, append_desc, "\n\n")
+	}
+
+	for tag_name, tag_values := range entry.Tags {
+		tag_name = strings.TrimPrefix(tag_name, "@")
+		if tag_values == nil {
+			tag_values = []string{""}
+		}
+		comments.Tags[tag_name] = tag_values
+	}
+
+	if entry.Hidden != nil {
+		hidden := *entry.Hidden
+		comments.Hidden = &hidden
+	}
+
+	// A replaced description takes the place of the leading comment for the
+	// summary.
+	summary_text := proc.get_leading_text(comments)
+	if entry.Description != nil {
+		summary_text = comments.Description
+	}
+	proc.derive_fields(elem, summary_text)
+	for _, example := range entry.Examples {
+		overlay_example := new_example(example.Language, example.Body)
+		overlay_example.Title = example.Title
+		overlay_example.Origin = "overlay"
+		comments.Examples = append(comments.Examples, overlay_example)
+	}
+
+	remove_comment_warnings(data, elem.full_name)
+	resolve_element_links(data, symbols, elem)
+	check_element_examples(data, elem)
+}
+
+// Removes the warnings derived from the comments of the given element.
+func remove_comment_warnings(data *docdata.TemplateData, full_name string) {
+	warnings := make([]*docdata.Warning, 0, len(data.Warnings))
+	for _, warning := range data.Warnings {
+		if warning.Element == full_name &&
+			comment_warning_codes[warning.Code] {
+			continue
+		}
+		warnings = append(warnings, warning)
+	}
+	data.Warnings = warnings
+}
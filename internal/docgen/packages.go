@@ -0,0 +1,143 @@
+package docgen
+
+// This file contains the code to build the index of protobuf packages.
+
+// BSD 2-Clause License
+//
+// Copyright (c) 2023 Don Owens <don@regexguy.com>.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// * Redistributions of source code must retain the above copyright notice,
+//   this list of conditions and the following disclaimer.
+//
+// * Redistributions in binary form must reproduce the above copyright notice,
+//   this list of conditions and the following disclaimer in the documentation
+//   and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+import (
+	// Built-in/core modules.
+	"strings"
+
+	// Generated code.
+	// First-party modules.
+	docdata "github.com/cuberat/protoc-gen-docjson/internal/docdata"
+	util "github.com/cuberat/protoc-gen-docjson/internal/util"
+)
+
+type package_builder struct {
+	data         *docdata.PackageData
+	files        *util.FifoSet[string]
+	imports      *util.FifoSet[string]
+	ext_imports  *util.FifoSet[string]
+	descriptions []string
+}
+
+// Groups the files, messages, enums, services, and extensions by protobuf
+// package. Packages are listed in the order they are first seen in the file
+// list, and everything within a package is listed in declaration order.
+func add_packages(data *docdata.TemplateData) {
+	data.PackageList = make([]string, 0)
+	data.PackageMap = make(map[string]*docdata.PackageData)
+	builders := make(map[string]*package_builder)
+
+	for _, file_name := range data.FileList {
+		file_data := data.FileMap[file_name]
+		builder, ok := builders[file_data.Package]
+		if !ok {
+			builder = &package_builder{
+				data: &docdata.PackageData{
+					Name:       file_data.Package,
+					Messages:   make([]string, 0),
+					Enums:      make([]string, 0),
+					Services:   make([]string, 0),
+					Extensions: make([]string, 0),
+				},
+				files:        util.NewStringSet(),
+				imports:      util.NewStringSet(),
+				ext_imports:  util.NewStringSet(),
+				descriptions: make([]string, 0),
+			}
+			builders[file_data.Package] = builder
+			data.PackageList = append(data.PackageList, file_data.Package)
+			data.PackageMap[file_data.Package] = builder.data
+		}
+
+		builder.add_file(data, file_data)
+	}
+
+	for _, builder := range builders {
+		builder.data.Files = builder.files.GetItems()
+		builder.data.Imports = builder.imports.GetItems()
+		builder.data.ExternalImports = builder.ext_imports.GetItems()
+		builder.data.Description = strings.Join(builder.descriptions, "\n\n")
+	}
+}
+
+func (builder *package_builder) add_file(
+	data *docdata.TemplateData,
+	file_data *docdata.FileData,
+) {
+	pkg := builder.data
+	builder.files.Add(file_data.Name)
+
+	if file_data.PackageDecl != nil && file_data.PackageDecl.Description != "" {
+		builder.descriptions =
+			append(builder.descriptions, file_data.PackageDecl.Description)
+	}
+
+	// Imported packages come from the import declarations, which know the
+	// packages of all imported files, including the ones not being generated.
+	for _, import_decl := range file_data.Imports {
+		if import_decl.Package == "" || import_decl.Package == pkg.Name {
+			continue
+		}
+		builder.imports.Add(import_decl.Package)
+	}
+	builder.ext_imports.Update(file_data.ExternalDependencies)
+
+	pkg.Messages, pkg.Enums =
+		add_package_messages(file_data.Messages, pkg.Messages, pkg.Enums)
+	for _, enum_data := range file_data.Enums {
+		pkg.Enums = append(pkg.Enums, enum_data.FullName)
+	}
+
+	for _, svc := range file_data.Services {
+		pkg.Services = append(pkg.Services, svc.FullName)
+	}
+
+	for _, ext := range file_data.Extensions {
+		pkg.Extensions = append(pkg.Extensions, ext.FullName)
+	}
+}
+
+// Appends the names of the given messages, and the messages and enums nested
+// within them, to the provided lists.
+func add_package_messages(
+	messages []*docdata.MessageData,
+	msg_names, enum_names []string,
+) ([]string, []string) {
+	for _, msg := range messages {
+		msg_names = append(msg_names, msg.FullName)
+		for _, enum_data := range msg.Enums {
+			enum_names = append(enum_names, enum_data.FullName)
+		}
+		msg_names, enum_names =
+			add_package_messages(msg.NestedMessages, msg_names, enum_names)
+	}
+
+	return msg_names, enum_names
+}
@@ -0,0 +1,201 @@
+package docgen
+
+// This file contains the code to describe how each field is represented by the
+// protobuf JSON mapping. See
+// https://protobuf.dev/programming-guides/proto3/#json for details.
+
+// BSD 2-Clause License
+//
+// Copyright (c) 2023 Don Owens <don@regexguy.com>.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// * Redistributions of source code must retain the above copyright notice,
+//   this list of conditions and the following disclaimer.
+//
+// * Redistributions in binary form must reproduce the above copyright notice,
+//   this list of conditions and the following disclaimer in the documentation
+//   and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+import (
+	// Built-in/core modules.
+	"fmt"
+	"strings"
+
+	// First-party modules.
+	docdata "github.com/cuberat/protoc-gen-docjson/internal/docdata"
+)
+
+type json_value_info struct {
+	JSONType    string
+	Encoding    string
+	Description string
+}
+
+// JSON representation of the well-known types, keyed by fully-qualified type
+// name.
+var WELL_KNOWN_JSON_TYPES = map[string]*json_value_info{
+	"google.protobuf.Timestamp": {"string", "timestamp",
+		`RFC 3339 timestamp string, e.g., "1972-01-01T10:00:20.021Z"`},
+	"google.protobuf.Duration": {"string", "duration",
+		`duration string in seconds with an "s" suffix, e.g., "1.000340012s"`},
+	"google.protobuf.FieldMask": {"string", "field_mask",
+		`comma-separated list of field paths in lowerCamelCase, e.g., "f.fooBar,h"`},
+	"google.protobuf.Struct": {"object", "struct",
+		"arbitrary JSON object"},
+	"google.protobuf.Value": {"any", "value",
+		"arbitrary JSON value"},
+	"google.protobuf.ListValue": {"array", "list_value",
+		"arbitrary JSON array"},
+	"google.protobuf.NullValue": {"null", "null",
+		"JSON null"},
+	"google.protobuf.Any": {"object", "any",
+		`JSON object with an "@type" member holding the type URL`},
+	"google.protobuf.Empty": {"object", "empty",
+		"empty JSON object"},
+	"google.protobuf.BoolValue": {"boolean", "wrapper",
+		"JSON boolean, or null"},
+	"google.protobuf.StringValue": {"string", "wrapper",
+		"JSON string, or null"},
+	"google.protobuf.BytesValue": {"string", "wrapper",
+		"base64-encoded string, or null"},
+	"google.protobuf.Int32Value": {"number", "wrapper",
+		"JSON number, or null"},
+	"google.protobuf.UInt32Value": {"number", "wrapper",
+		"JSON number, or null"},
+	"google.protobuf.Int64Value": {"string", "wrapper",
+		"64-bit integer encoded as a decimal string, or null"},
+	"google.protobuf.UInt64Value": {"string", "wrapper",
+		"64-bit integer encoded as a decimal string, or null"},
+	"google.protobuf.FloatValue": {"number", "wrapper",
+		`JSON number, "NaN", "Infinity", "-Infinity", or null`},
+	"google.protobuf.DoubleValue": {"number", "wrapper",
+		`JSON number, "NaN", "Infinity", "-Infinity", or null`},
+}
+
+// Walks all of the messages and fills in the JSON mapping for each field. This
+// needs to run after the message map is populated, so that map fields can be
+// detected via their map entry messages.
+func add_json_mappings(data *docdata.TemplateData) {
+	for _, msg := range data.MessageMap {
+		for _, field := range msg.Fields {
+			field.JSONMapping = get_json_mapping(data, field)
+		}
+	}
+}
+
+func get_json_mapping(
+	data *docdata.TemplateData,
+	field *docdata.FieldData,
+) *docdata.JSONMapping {
+	mapping := new(docdata.JSONMapping)
+
+	if entry := data.GetMapEntry(field); entry != nil {
+		key_field, val_field := entry.Fields[0], entry.Fields[1]
+		val_info := get_json_value_info(val_field.Kind, val_field.FullTypeName)
+
+		mapping.JSONType = "object"
+		mapping.Container = "map"
+		mapping.ValueJSONType = val_info.JSONType
+		mapping.ValueEncoding = val_info.Encoding
+		mapping.MapKeyType = key_field.FullTypeName
+		mapping.MapValueType = val_field.FullTypeName
+		if WELL_KNOWN_JSON_TYPES[val_field.FullTypeName] != nil {
+			mapping.WellKnownType = val_field.FullTypeName
+		}
+		mapping.Description = fmt.Sprintf("JSON object with %s keys encoded "+
+			"as strings and values encoded as %s", key_field.FullTypeName,
+			val_info.Description)
+
+		return mapping
+	}
+
+	val_info := get_json_value_info(field.Kind, field.FullTypeName)
+	mapping.ValueJSONType = val_info.JSONType
+	mapping.ValueEncoding = val_info.Encoding
+	if WELL_KNOWN_JSON_TYPES[field.FullTypeName] != nil {
+		mapping.WellKnownType = field.FullTypeName
+	}
+
+	if field.Label == "repeated" {
+		mapping.JSONType = "array"
+		mapping.Container = "array"
+		mapping.Description = "JSON array with each element encoded as " +
+			val_info.Description
+	} else {
+		mapping.JSONType = val_info.JSONType
+		mapping.Description = val_info.Description
+	}
+
+	return mapping
+}
+
+func get_json_value_info(kind, full_type string) *json_value_info {
+	if info, ok := WELL_KNOWN_JSON_TYPES[full_type]; ok {
+		return info
+	}
+
+	switch kind {
+	case "double", "float":
+		return &json_value_info{"number", "float",
+			`JSON number, or one of the strings "NaN", "Infinity", ` +
+				`"-Infinity"`}
+	case "int64", "uint64", "sint64", "fixed64", "sfixed64":
+		return &json_value_info{"string", "int64_string",
+			"64-bit integer encoded as a decimal string"}
+	case "int32", "uint32", "sint32", "fixed32", "sfixed32":
+		return &json_value_info{"number", "integer", "JSON number"}
+	case "bool":
+		return &json_value_info{"boolean", "bool", "JSON boolean"}
+	case "string":
+		return &json_value_info{"string", "string", "JSON string"}
+	case "bytes":
+		return &json_value_info{"string", "base64",
+			"base64-encoded string (standard encoding with padding)"}
+	case "enum":
+		return &json_value_info{"string", "enum_name",
+			"name of the enum value as a string (the integer value is " +
+				"also accepted by parsers)"}
+	case "message", "group":
+		return &json_value_info{"object", "message",
+			fmt.Sprintf("JSON object for message %s", full_type)}
+	}
+
+	return &json_value_info{"any", "unknown", "unknown encoding"}
+}
+
+// Returns the JSON name the protobuf compiler derives from a field name when
+// the `json_name` option is not given: underscores are dropped and the letter
+// following each underscore is upper-cased.
+func get_default_json_name(name string) string {
+	var builder strings.Builder
+	capitalize_next := false
+	for _, char := range name {
+		if char == '_' {
+			capitalize_next = true
+			continue
+		}
+
+		if capitalize_next {
+			builder.WriteString(strings.ToUpper(string(char)))
+			capitalize_next = false
+		} else {
+			builder.WriteRune(char)
+		}
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,121 @@
+package docgen
+
+// This file contains the code to record where each element is defined in the
+// protobuf specifications.
+
+// BSD 2-Clause License
+//
+// Copyright (c) 2023 Don Owens <don@regexguy.com>.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// * Redistributions of source code must retain the above copyright notice,
+//   this list of conditions and the following disclaimer.
+//
+// * Redistributions in binary form must reproduce the above copyright notice,
+//   this list of conditions and the following disclaimer in the documentation
+//   and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+import (
+	// Built-in/core modules.
+	"strconv"
+	"strings"
+
+	// Third-party modules.
+	desc_pb "google.golang.org/protobuf/types/descriptorpb"
+
+	// Generated code.
+	// First-party modules.
+	docdata "github.com/cuberat/protoc-gen-docjson/internal/docdata"
+)
+
+const DEFAULT_SOURCE_REF = "HEAD"
+
+// Converts the span of a source code location to a `SourceLocation`. The span
+// from the protobuf compiler is zero-based, and has three elements if the
+// start and end lines are the same (start line, start column, end column), or
+// four otherwise (start line, start column, end line, end column).
+func get_source_location(
+	file_name string,
+	location *desc_pb.SourceCodeInfo_Location,
+	conf *docdata.Config,
+) *docdata.SourceLocation {
+	span := location.GetSpan()
+	if len(span) < 3 {
+		return nil
+	}
+
+	source := &docdata.SourceLocation{
+		File:        file_name,
+		StartLine:   span[0] + 1,
+		StartColumn: span[1] + 1,
+		EndLine:     span[0] + 1,
+		EndColumn:   span[2] + 1,
+	}
+
+	if len(span) == 4 {
+		source.EndLine = span[2] + 1
+		source.EndColumn = span[3] + 1
+	}
+
+	source.URL = get_source_url(source, conf)
+
+	return source
+}
+
+// Expands the `source_url_template` plugin option for the given location.
+// Supported placeholders are `{file}`, `{ref}`, `{line}`, `{column}`,
+// `{end_line}`, and `{end_column}`.
+func get_source_url(
+	source *docdata.SourceLocation,
+	conf *docdata.Config,
+) string {
+	url_template := conf.PluginOpts.SourceURLTemplate
+	if url_template == "" {
+		return ""
+	}
+
+	ref := conf.PluginOpts.SourceRef
+	if ref == "" {
+		ref = DEFAULT_SOURCE_REF
+	}
+
+	replacer := strings.NewReplacer(
+		"{file}", source.File,
+		"{ref}", ref,
+		"{line}", strconv.Itoa(int(source.StartLine)),
+		"{column}", strconv.Itoa(int(source.StartColumn)),
+		"{end_line}", strconv.Itoa(int(source.EndLine)),
+		"{end_column}", strconv.Itoa(int(source.EndColumn)),
+	)
+
+	return replacer.Replace(url_template)
+}
+
+// Returns true if the inner location lies within the outer location.
+func source_contains(outer, inner *docdata.SourceLocation) bool {
+	if outer == nil || inner == nil {
+		return false
+	}
+
+	starts_after := inner.StartLine > outer.StartLine ||
+		(inner.StartLine == outer.StartLine &&
+			inner.StartColumn >= outer.StartColumn)
+	ends_before := inner.EndLine < outer.EndLine ||
+		(inner.EndLine == outer.EndLine && inner.EndColumn <= outer.EndColumn)
+
+	return starts_after && ends_before
+}
@@ -0,0 +1,114 @@
+package docgen
+
+// This file contains the code to collect TODO and FIXME notes from comments,
+// so that they can be reviewed before an API is declared stable.
+
+// BSD 2-Clause License
+//
+// Copyright (c) 2023 Don Owens <don@regexguy.com>.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// * Redistributions of source code must retain the above copyright notice,
+//   this list of conditions and the following disclaimer.
+//
+// * Redistributions in binary form must reproduce the above copyright notice,
+//   this list of conditions and the following disclaimer in the documentation
+//   and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+import (
+	// Built-in/core modules.
+	"path"
+	"regexp"
+	"strings"
+
+	// Third-party modules.
+	log "github.com/sirupsen/logrus"
+
+	// Generated code.
+	// First-party modules.
+	docdata "github.com/cuberat/protoc-gen-docjson/internal/docdata"
+)
+
+// Doc tag that marks a file as stable when used in the comments for the
+// `syntax` or `package` statement. This is always recognized.
+const STABLE_TAG = "stable"
+
+// E.g., "TODO: fix this", "FIXME(alice) handle errors", or "XXX why?".
+var todo_re = regexp.MustCompile(
+	`\b(TODO|FIXME|XXX)\b(?:\(([^)]*)\))?:?[ \t]*(.*)`)
+
+// Marks the stable files, then collects the TODO, FIXME, and XXX notes from
+// the comments of every element into `TodoList`, in file order.
+func collect_todos(data *docdata.TemplateData, conf *docdata.Config) {
+	for _, file_name := range data.FileList {
+		file_data := data.FileMap[file_name]
+		file_data.Stable = is_stable_file(file_data, conf)
+	}
+
+	data.TodoList = make([]*docdata.TodoItem, 0)
+	for_each_comment_data(data, func(elem *comment_element) {
+		comments := elem.comments
+		texts := make([]string, 0, len(comments.LeadingDetachedComments)+2)
+		texts = append(texts, comments.LeadingDetachedComments...)
+		texts = append(texts, comments.LeadingComments,
+			comments.TrailingComments)
+
+		for _, text := range texts {
+			for _, match := range todo_re.FindAllStringSubmatch(text, -1) {
+				data.TodoList = append(data.TodoList, &docdata.TodoItem{
+					Marker:  match[1],
+					Owner:   match[2],
+					Text:    strings.TrimSpace(match[3]),
+					Element: elem.full_name,
+					Kind:    elem.kind,
+					File:    elem.file,
+					Stable:  data.FileMap[elem.file].Stable,
+					Source:  elem.source,
+				})
+			}
+		}
+	})
+}
+
+// Returns true if the file has a `@stable` tag in the comments for its
+// `syntax` or `package` statement, or matches one of the `stable_file`
+// patterns.
+func is_stable_file(file_data *docdata.FileData, conf *docdata.Config) bool {
+	if file_data.Syntax != nil {
+		if _, ok := file_data.Syntax.Tags[STABLE_TAG]; ok {
+			return true
+		}
+	}
+	if file_data.PackageDecl != nil {
+		if _, ok := file_data.PackageDecl.Tags[STABLE_TAG]; ok {
+			return true
+		}
+	}
+
+	for _, pattern := range conf.PluginOpts.StableFiles {
+		matched, err := path.Match(pattern, file_data.Name)
+		if err != nil {
+			log.Errorf("invalid stable_file pattern %q: %s", pattern, err)
+			continue
+		}
+		if matched {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,130 @@
+package docgen
+
+// This file contains the code to expand `${name}` variables in comments, from
+// the `var` and `vars_file` plugin options.
+
+// BSD 2-Clause License
+//
+// Copyright (c) 2023 Don Owens <don@regexguy.com>.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// * Redistributions of source code must retain the above copyright notice,
+//   this list of conditions and the following disclaimer.
+//
+// * Redistributions in binary form must reproduce the above copyright notice,
+//   this list of conditions and the following disclaimer in the documentation
+//   and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+import (
+	// Built-in/core modules.
+	"fmt"
+	"os"
+	"regexp"
+
+	// Third-party modules.
+	yaml "gopkg.in/yaml.v3"
+
+	// Generated code.
+	// First-party modules.
+	docdata "github.com/cuberat/protoc-gen-docjson/internal/docdata"
+)
+
+// Matches `${name}`, or `$${name}` for a literal `${name}`.
+var comment_var_re = regexp.MustCompile(`\$(\$?)\{([A-Za-z_][\w.-]*)\}`)
+
+// Sets `conf.Vars` from the vars files, followed by the `var` options, so
+// that the options take precedence.
+func load_comment_vars(conf *docdata.Config) error {
+	conf.Vars = make(map[string]string)
+
+	for _, vars_path := range conf.PluginOpts.VarsFiles {
+		content, err := os.ReadFile(vars_path)
+		if err != nil {
+			return fmt.Errorf("couldn't read vars file: %w", err)
+		}
+
+		file_vars := make(map[string]string)
+		if err := yaml.Unmarshal(content, &file_vars); err != nil {
+			return fmt.Errorf("couldn't parse vars file %s: %w", vars_path,
+				err)
+		}
+		for name, val := range file_vars {
+			conf.Vars[name] = val
+		}
+	}
+
+	for name, val := range conf.PluginOpts.Vars {
+		conf.Vars[name] = val
+	}
+
+	return nil
+}
+
+// Expands the variables in the comments of an element, adding a warning for
+// each variable that isn't defined. Undefined variables are replaced with an
+// empty string.
+func expand_comment_vars(
+	data *docdata.TemplateData,
+	elem *comment_element,
+	vars map[string]string,
+) {
+	comments := elem.comments
+	undefined := make(map[string]bool)
+
+	expand := func(text string) string {
+		return expand_vars(text, vars, func(name string) {
+			if undefined[name] {
+				return
+			}
+			undefined[name] = true
+			add_warning(data, "undefined_variable", elem,
+				"variable ${%s} is not defined", name)
+		})
+	}
+
+	comments.LeadingComments = expand(comments.LeadingComments)
+	comments.TrailingComments = expand(comments.TrailingComments)
+	for i, detached := range comments.LeadingDetachedComments {
+		comments.LeadingDetachedComments[i] = expand(detached)
+	}
+}
+
+// Expands the variables in `text`, calling `on_undefined` for each one that
+// isn't in `vars`.
+func expand_vars(
+	text string,
+	vars map[string]string,
+	on_undefined func(name string),
+) string {
+	if !comment_var_re.MatchString(text) {
+		return text
+	}
+
+	return comment_var_re.ReplaceAllStringFunc(text, func(ref string) string {
+		match := comment_var_re.FindStringSubmatch(ref)
+		if match[1] != "" {
+			return ref[1:]
+		}
+
+		val, ok := vars[match[2]]
+		if !ok {
+			on_undefined(match[2])
+		}
+
+		return val
+	})
+}
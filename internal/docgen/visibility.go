@@ -0,0 +1,450 @@
+package docgen
+
+// This file contains the code to remove internal elements from the output,
+// e.g., so that docs generated from the same specifications can be published
+// externally.
+
+// BSD 2-Clause License
+//
+// Copyright (c) 2023 Don Owens <don@regexguy.com>.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// * Redistributions of source code must retain the above copyright notice,
+//   this list of conditions and the following disclaimer.
+//
+// * Redistributions in binary form must reproduce the above copyright notice,
+//   this list of conditions and the following disclaimer in the documentation
+//   and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+import (
+	// Built-in/core modules.
+	"fmt"
+	"strings"
+
+	// Third-party modules.
+	log "github.com/sirupsen/logrus"
+
+	// Generated code.
+	// First-party modules.
+	docdata "github.com/cuberat/protoc-gen-docjson/internal/docdata"
+)
+
+// Doc tags that hide an element when the `hide_tag` plugin option is not
+// provided.
+var DEFAULT_HIDE_TAGS = []string{"internal"}
+
+// A custom option that hides the elements it is set on.
+type hide_option_rule struct {
+	// Short name of the option, as used for keys in `custom_options`.
+	name string
+
+	// Value the option must have for the element to be hidden. If
+	// `has_value` is false, the element is hidden if the option is set to
+	// true (for boolean options) or set at all (for other options).
+	value     string
+	has_value bool
+}
+
+type visibility_filter struct {
+	data      *docdata.TemplateData
+	hide_tags []string
+	rules     []*hide_option_rule
+
+	// Fully-qualified names of the hidden elements.
+	hidden map[string]bool
+}
+
+// Removes the elements marked as hidden by the `hide_option` and `hide_tag`
+// plugin options, then rebuilds the indices and dependency lists so that
+// hidden elements don't show up anywhere. Warnings are added for fields and
+// methods that refer to hidden types, and for comment links to hidden
+// elements.
+func FilterHidden(data *docdata.TemplateData, conf *docdata.Config) {
+	filter := &visibility_filter{
+		data:      data,
+		hide_tags: get_hide_tags(conf),
+		rules:     parse_hide_options(conf.PluginOpts.HideOptions),
+		hidden:    make(map[string]bool),
+	}
+
+	for _, file_name := range data.FileList {
+		filter.filter_file(data.FileMap[file_name])
+	}
+
+	if len(filter.hidden) == 0 {
+		return
+	}
+
+	log.Debugf("hiding %d elements", len(filter.hidden))
+
+	filter.filter_warnings()
+	filter.filter_todos()
+	for _, file_name := range data.FileList {
+		filter.check_type_refs(data.FileMap[file_name])
+	}
+
+	rebuild_indices(data)
+	filter.filter_dependencies()
+	filter.filter_links()
+}
+
+func get_hide_tags(conf *docdata.Config) []string {
+	if conf.PluginOpts.HideTags == nil {
+		return DEFAULT_HIDE_TAGS
+	}
+
+	return conf.PluginOpts.HideTags
+}
+
+// Parses the values of the `hide_option` plugin option, e.g.,
+// `visibility=INTERNAL` or `(acme.internal)`.
+func parse_hide_options(hide_options []string) []*hide_option_rule {
+	rules := make([]*hide_option_rule, 0, len(hide_options))
+	for _, hide_option := range hide_options {
+		name, value, has_value := strings.Cut(hide_option, "=")
+		name = strings.Trim(strings.TrimSpace(name), "()")
+		_, name = split_full_name(name)
+		if name == "" {
+			log.Errorf("invalid hide_option %q", hide_option)
+			continue
+		}
+
+		rules = append(rules, &hide_option_rule{
+			name:      name,
+			value:     strings.TrimSpace(value),
+			has_value: has_value,
+		})
+	}
+
+	return rules
+}
+
+// Returns true if the element has one of the hide tags, or a custom option
+// matching one of the hide rules.
+func (filter *visibility_filter) is_hidden(
+	comments *docdata.CommentData,
+	custom_options map[string]any,
+) bool {
+	if comments.Hidden != nil {
+		return *comments.Hidden
+	}
+
+	for _, tag_name := range filter.hide_tags {
+		if _, ok := comments.Tags[tag_name]; ok {
+			return true
+		}
+	}
+
+	for _, rule := range filter.rules {
+		val, ok := custom_options[rule.name]
+		if !ok {
+			continue
+		}
+
+		if rule.has_value {
+			if fmt.Sprint(val) == rule.value {
+				return true
+			}
+			continue
+		}
+
+		if bool_val, is_bool := val.(bool); !is_bool || bool_val {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (filter *visibility_filter) filter_file(file_data *docdata.FileData) {
+	file_data.Messages = filter.filter_messages(file_data.Messages)
+	file_data.Enums = filter.filter_enums(file_data.Enums)
+
+	services := make([]*docdata.ServiceData, 0, len(file_data.Services))
+	for _, svc := range file_data.Services {
+		if filter.is_hidden(&svc.CommentData, svc.CustomOptions) {
+			filter.hidden[svc.FullName] = true
+			for _, method := range svc.Methods {
+				filter.hidden[method.FullName] = true
+			}
+			continue
+		}
+
+		methods := make([]*docdata.MethodData, 0, len(svc.Methods))
+		for _, method := range svc.Methods {
+			if filter.is_hidden(&method.CommentData, method.CustomOptions) {
+				filter.hidden[method.FullName] = true
+				continue
+			}
+			methods = append(methods, method)
+		}
+		svc.Methods = methods
+
+		services = append(services, svc)
+	}
+	file_data.Services = services
+
+	extensions := make([]*docdata.FileExtension, 0, len(file_data.Extensions))
+	for _, ext := range file_data.Extensions {
+		if filter.is_hidden(&ext.CommentData, nil) {
+			filter.hidden[ext.FullName] = true
+			continue
+		}
+		extensions = append(extensions, ext)
+	}
+	file_data.Extensions = extensions
+
+	for option_type, option_exts := range file_data.DeclaredCustomOptions {
+		kept := make([]*docdata.FileExtension, 0, len(option_exts))
+		for _, ext := range option_exts {
+			if !filter.hidden[ext.FullName] {
+				kept = append(kept, ext)
+			}
+		}
+		file_data.DeclaredCustomOptions[option_type] = kept
+	}
+}
+
+func (filter *visibility_filter) filter_messages(
+	messages []*docdata.MessageData,
+) []*docdata.MessageData {
+	kept := make([]*docdata.MessageData, 0, len(messages))
+	for _, msg := range messages {
+		if filter.is_hidden(&msg.CommentData, msg.CustomOptions) {
+			filter.hide_message(msg)
+			continue
+		}
+
+		filter.filter_message_fields(msg)
+		msg.Enums = filter.filter_enums(msg.Enums)
+		msg.NestedMessages = filter.filter_messages(msg.NestedMessages)
+
+		kept = append(kept, msg)
+	}
+
+	return kept
+}
+
+// Removes hidden fields and oneofs from the message. The fields in a hidden
+// oneof are hidden as well.
+func (filter *visibility_filter) filter_message_fields(
+	msg *docdata.MessageData,
+) {
+	oneofs := make([]*docdata.OneOfData, 0, len(msg.OneofDecls))
+	oneof_indices := make(map[string]int32, len(msg.OneofDecls))
+	for _, oneof := range msg.OneofDecls {
+		if filter.is_hidden(&oneof.CommentData, nil) {
+			filter.hidden[oneof.FullName] = true
+			continue
+		}
+		oneof_indices[oneof.FullName] = int32(len(oneofs))
+		oneofs = append(oneofs, oneof)
+	}
+	msg.OneofDecls = oneofs
+
+	fields := make([]*docdata.FieldData, 0, len(msg.Fields))
+	for _, field := range msg.Fields {
+		if filter.is_hidden(&field.CommentData, field.CustomOptions) ||
+			(field.InOneof && filter.hidden[field.OneofFullName]) {
+			filter.hidden[field.FullName] = true
+			continue
+		}
+
+		// Keep the oneof indices pointing at the right declarations.
+		if field.InOneof {
+			field.OneofIndex = oneof_indices[field.OneofFullName]
+		}
+
+		fields = append(fields, field)
+	}
+	msg.Fields = fields
+}
+
+// Marks the message and everything in it as hidden.
+func (filter *visibility_filter) hide_message(msg *docdata.MessageData) {
+	filter.hidden[msg.FullName] = true
+	for _, field := range msg.Fields {
+		filter.hidden[field.FullName] = true
+	}
+	for _, oneof := range msg.OneofDecls {
+		filter.hidden[oneof.FullName] = true
+	}
+	for _, enum_data := range msg.Enums {
+		filter.hide_enum(enum_data)
+	}
+	for _, nested_msg := range msg.NestedMessages {
+		filter.hide_message(nested_msg)
+	}
+}
+
+func (filter *visibility_filter) filter_enums(
+	enums []*docdata.EnumData,
+) []*docdata.EnumData {
+	kept := make([]*docdata.EnumData, 0, len(enums))
+	for _, enum_data := range enums {
+		if filter.is_hidden(&enum_data.CommentData, enum_data.CustomOptions) {
+			filter.hide_enum(enum_data)
+			continue
+		}
+
+		values := make([]*docdata.EnumValue, 0, len(enum_data.Values))
+		for _, enum_val := range enum_data.Values {
+			if filter.is_hidden(&enum_val.CommentData, enum_val.CustomOptions) {
+				filter.hidden[qualify_name(enum_data.FullName, enum_val.Name)] =
+					true
+				continue
+			}
+			values = append(values, enum_val)
+		}
+		enum_data.Values = values
+
+		kept = append(kept, enum_data)
+	}
+
+	return kept
+}
+
+// Marks the enum and its values as hidden.
+func (filter *visibility_filter) hide_enum(enum_data *docdata.EnumData) {
+	filter.hidden[enum_data.FullName] = true
+	for _, enum_val := range enum_data.Values {
+		filter.hidden[qualify_name(enum_data.FullName, enum_val.Name)] = true
+	}
+}
+
+// Adds warnings for visible fields and methods that refer to hidden types.
+// These are left in place, since removing them would misrepresent the
+// messages and services they belong to.
+func (filter *visibility_filter) check_type_refs(file_data *docdata.FileData) {
+	var check_messages func(messages []*docdata.MessageData)
+	check_messages = func(messages []*docdata.MessageData) {
+		for _, msg := range messages {
+			for _, field := range msg.Fields {
+				if !filter.hidden[field.FullTypeName] {
+					continue
+				}
+				add_warning(filter.data, "hidden_type_reference",
+					&comment_element{
+						kind:      "field",
+						full_name: field.FullName,
+						file:      file_data.Name,
+						source:    field.Source,
+					},
+					"field refers to hidden type %s", field.FullTypeName)
+			}
+			check_messages(msg.NestedMessages)
+		}
+	}
+	check_messages(file_data.Messages)
+
+	for _, svc := range file_data.Services {
+		for _, method := range svc.Methods {
+			for _, type_name := range []string{
+				method.RequestFullType,
+				method.ResponseFullType,
+			} {
+				if !filter.hidden[type_name] {
+					continue
+				}
+				add_warning(filter.data, "hidden_type_reference",
+					&comment_element{
+						kind:      "method",
+						full_name: method.FullName,
+						file:      file_data.Name,
+						source:    method.Source,
+					},
+					"method refers to hidden type %s", type_name)
+			}
+		}
+	}
+}
+
+// Clears and rebuilds all of the indices (e.g., `MessageMap` and
+// `MessageList`) from the file data.
+func rebuild_indices(data *docdata.TemplateData) {
+	data.ServiceList = nil
+	data.ServiceMap = nil
+	data.MessageList = nil
+	data.MessageMap = nil
+	data.ExtensionList = nil
+	data.ExtensionMap = nil
+	data.EnumList = nil
+	data.EnumMap = nil
+
+	massage_data(data)
+}
+
+// Removes hidden types from the dependency lists. Dependencies are listed by
+// type, so types used by visible fields and methods may still be hidden.
+func (filter *visibility_filter) filter_dependencies() {
+	filter_deps := func(deps_map map[string][]string) {
+		for name, deps := range deps_map {
+			kept := make([]string, 0, len(deps))
+			for _, dep := range deps {
+				if !filter.hidden[dep] {
+					kept = append(kept, dep)
+				}
+			}
+			deps_map[name] = kept
+		}
+	}
+
+	filter_deps(filter.data.MessageDeps)
+	filter_deps(filter.data.ServiceDeps)
+	add_service_file_deps(filter.data)
+}
+
+// Removes warnings about hidden elements, so that their names don't leak
+// through the warnings.
+func (filter *visibility_filter) filter_warnings() {
+	warnings := make([]*docdata.Warning, 0, len(filter.data.Warnings))
+	for _, warning := range filter.data.Warnings {
+		if !filter.hidden[warning.Element] {
+			warnings = append(warnings, warning)
+		}
+	}
+	filter.data.Warnings = warnings
+}
+
+// Removes the notes in the comments of hidden elements.
+func (filter *visibility_filter) filter_todos() {
+	todos := make([]*docdata.TodoItem, 0, len(filter.data.TodoList))
+	for _, todo := range filter.data.TodoList {
+		if !filter.hidden[todo.Element] {
+			todos = append(todos, todo)
+		}
+	}
+	filter.data.TodoList = todos
+}
+
+// Removes comment links to hidden elements, adding a warning for each, since
+// the reference is still in the comment text.
+func (filter *visibility_filter) filter_links() {
+	for_each_comment_data(filter.data, func(elem *comment_element) {
+		links := make([]*docdata.CommentLink, 0, len(elem.comments.Links))
+		for _, link := range elem.comments.Links {
+			if !filter.hidden[link.Target] {
+				links = append(links, link)
+				continue
+			}
+			add_warning(filter.data, "hidden_link", elem,
+				"reference %s is to hidden element %s", link.Text,
+				link.Target)
+		}
+		elem.comments.Links = links
+	})
+}
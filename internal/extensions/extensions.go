@@ -227,6 +227,13 @@ func (proc *CustomOptionProcessor) ExtractEnumOptions(
 		return
 	}
 
+	if loc_path[0] == 2 && len(loc_path) > 2 {
+		// Enum value.
+		value := enum_data.Values[loc_path[1]]
+		proc.ExtractEnumValueOptions(value, loc_path[2:], loc)
+		return
+	}
+
 	if loc_path[0] == 3 && len(loc_path) == 2 {
 		if enum_data.CustomOptions == nil {
 			enum_data.CustomOptions = make(map[string]any)
@@ -250,6 +257,30 @@ func (proc *CustomOptionProcessor) ExtractEnumOptions(
 	}
 }
 
+func (proc *CustomOptionProcessor) ExtractEnumValueOptions(
+	value *docdata.EnumValue,
+	loc_path []int32,
+	loc *desc_pb.SourceCodeInfo_Location,
+) {
+	if loc_path[0] == 3 && len(loc_path) == 2 {
+		name, val, err := proc.BuildOptionVal(loc_path[1],
+			".google.protobuf.EnumValueOptions", loc)
+		if err != nil {
+			return
+		}
+
+		if value.CustomOptions == nil {
+			value.CustomOptions = make(map[string]any)
+		}
+
+		value.CustomOptions[name] = val
+
+		log.Debugf("found custom enum value option %q = %v", name, val)
+
+		return
+	}
+}
+
 func (proc *CustomOptionProcessor) ExtractFileOptions(
 	this_file *docdata.FileData,
 	loc_path []int32,
@@ -377,6 +408,10 @@ func convert_ext_val(
 			log.Errorf("unable to parse %s value %q", ext_type, val_string)
 		}
 		return uint32(num)
+
+	case "enum":
+		// The name of the enum value.
+		return val_string
 	}
 
 	return ""
@@ -36,6 +36,7 @@ import (
 	json "encoding/json"
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
 
 	// Third-party modules.
@@ -50,6 +51,7 @@ import (
 	// First-party modules.
 	docdata "github.com/cuberat/protoc-gen-docjson/internal/docdata"
 	docgen "github.com/cuberat/protoc-gen-docjson/internal/docgen"
+	render "github.com/cuberat/protoc-gen-docjson/internal/render"
 )
 
 func ProcessCodeGenRequest(
@@ -83,24 +85,81 @@ func ProcessCodeGenRequest(
 	}
 
 	template_data, err := docgen.GenDocData(conf, protos_to_process,
-		files_to_generate)
+		files_to_generate, gen_req.ProtoFile)
 	if err != nil {
 		err = fmt.Errorf("couldn't generate template data: %w", err)
 		send_code_gen_err(err, writer)
 		return err
 	}
 
-	content, err := serialize_content(template_data, conf)
-	if err != nil {
+	if err = docgen.ApplyOverlays(template_data, conf); err != nil {
 		return send_code_gen_err(err, writer)
 	}
-	file := &pluginpb.CodeGeneratorResponse_File{
-		Name:    &conf.PluginOpts.OutFile,
-		Content: &content,
+
+	docgen.FilterHidden(template_data, conf)
+
+	if conf.PluginOpts.FailOnStableTodos {
+		stable_todos := make([]string, 0)
+		for _, todo := range template_data.TodoList {
+			if todo.Stable {
+				stable_todos = append(stable_todos, todo.String())
+			}
+		}
+		if len(stable_todos) > 0 {
+			err = fmt.Errorf("found %d notes in stable files "+
+				"(fail_on_stable_todos is set):\n%s", len(stable_todos),
+				strings.Join(stable_todos, "\n"))
+			return send_code_gen_err(err, writer)
+		}
+	}
+
+	if conf.PluginOpts.FailOnWarnings && len(template_data.Warnings) > 0 {
+		warnings := make([]string, 0, len(template_data.Warnings))
+		for _, warning := range template_data.Warnings {
+			warnings = append(warnings, warning.String())
+		}
+		err = fmt.Errorf("found %d warnings (fail_on_warnings is set):\n%s",
+			len(warnings), strings.Join(warnings, "\n"))
+		return send_code_gen_err(err, writer)
 	}
 
 	gen_resp := new(pluginpb.CodeGeneratorResponse)
-	gen_resp.File = []*pluginpb.CodeGeneratorResponse_File{file}
+
+	if render.HasGenerator(conf.PluginOpts.OutFormat) {
+		generated_files, err := render.Generate(template_data, conf)
+		if err != nil {
+			return send_code_gen_err(err, writer)
+		}
+		for _, generated := range generated_files {
+			gen_resp.File = append(gen_resp.File,
+				&pluginpb.CodeGeneratorResponse_File{
+					Name:    proto.String(generated.Name),
+					Content: proto.String(generated.Content),
+				})
+		}
+	} else {
+		content, err := serialize_content(template_data, conf)
+		if err != nil {
+			return send_code_gen_err(err, writer)
+		}
+		gen_resp.File = append(gen_resp.File,
+			&pluginpb.CodeGeneratorResponse_File{
+				Name:    &conf.PluginOpts.OutFile,
+				Content: &content,
+			})
+	}
+
+	rendered_files, err := render.RenderTemplates(template_data, conf)
+	if err != nil {
+		return send_code_gen_err(err, writer)
+	}
+	for _, rendered := range rendered_files {
+		gen_resp.File = append(gen_resp.File,
+			&pluginpb.CodeGeneratorResponse_File{
+				Name:    proto.String(rendered.Name),
+				Content: proto.String(rendered.Content),
+			})
+	}
 
 	return send_code_gen_resp(gen_resp, writer)
 }
@@ -253,6 +312,77 @@ func parse_plugin_option(opts string) *docdata.PluginOpts {
 			options.OutFormat = opt_pair[1]
 		case "pretty":
 			options.PrettyPrint = true
+		case "source_url_template":
+			options.SourceURLTemplate = strings.TrimSpace(opt_pair[1])
+		case "source_ref":
+			options.SourceRef = strings.TrimSpace(opt_pair[1])
+		case "doc_tags":
+			options.DocTags = parse_doc_tags_option(opt_pair[1])
+		case "markdown":
+			options.Markdown = true
+		case "hide_option":
+			options.HideOptions = append(options.HideOptions, opt_pair[1])
+		case "hide_tag":
+			options.HideTags = parse_doc_tags_option(opt_pair[1])
+		case "stable_file":
+			options.StableFiles =
+				append(options.StableFiles, strings.TrimSpace(opt_pair[1]))
+		case "fail_on_stable_todos":
+			options.FailOnStableTodos = true
+		case "fail_on_warnings":
+			options.FailOnWarnings = true
+		case "var":
+			name, val, _ := strings.Cut(opt_pair[1], "=")
+			if options.Vars == nil {
+				options.Vars = make(map[string]string)
+			}
+			options.Vars[strings.TrimSpace(name)] = unescape_option_value(val)
+		case "vars_file":
+			options.VarsFiles =
+				append(options.VarsFiles, strings.TrimSpace(opt_pair[1]))
+		case "template":
+			tmpl_path, out_file, _ := strings.Cut(opt_pair[1], ":")
+			options.Templates = append(options.Templates,
+				&docdata.TemplateSpec{
+					Path:    strings.TrimSpace(tmpl_path),
+					OutFile: strings.TrimSpace(out_file),
+				})
+		case "template_dir":
+			options.TemplateDir = strings.TrimSpace(opt_pair[1])
+		case "split_by":
+			options.SplitBy = strings.TrimSpace(opt_pair[1])
+		case "api_title":
+			options.APITitle = unescape_option_value(opt_pair[1])
+		case "api_version":
+			options.APIVersion = strings.TrimSpace(opt_pair[1])
+		case "schema_bundle":
+			options.SchemaBundle = true
+		case "diagram_depth":
+			depth, err := strconv.Atoi(strings.TrimSpace(opt_pair[1]))
+			if err != nil || depth < 0 {
+				log.Errorf("invalid diagram_depth %q", opt_pair[1])
+				continue
+			}
+			options.DiagramDepth = depth
+		case "diagram_exclude_well_known":
+			options.DiagramExcludeWellKnown = true
+		case "diagram_cluster":
+			options.DiagramCluster = true
+		case "docusaurus_sidebars":
+			options.DocusaurusSidebars = true
+		case "overlay":
+			options.Overlays =
+				append(options.Overlays, strings.TrimSpace(opt_pair[1]))
+		case "summary_width":
+			width, err := strconv.Atoi(strings.TrimSpace(opt_pair[1]))
+			if err != nil {
+				log.Errorf("invalid summary_width %q: %s", opt_pair[1], err)
+				continue
+			}
+			options.SummaryWidth = &width
+		case "comment_separator":
+			separator := unescape_option_value(opt_pair[1])
+			options.CommentSeparator = &separator
 		}
 	}
 
@@ -261,6 +391,32 @@ func parse_plugin_option(opts string) *docdata.PluginOpts {
 	return options
 }
 
+// Parses a colon-separated list of doc tag names, e.g.,
+// `since:example:see`. A leading `@` on a name is ignored.
+func parse_doc_tags_option(opt_val string) []string {
+	tag_names := make([]string, 0)
+	for _, tag_name := range strings.Split(opt_val, ":") {
+		tag_name = strings.TrimPrefix(strings.TrimSpace(tag_name), "@")
+		if tag_name != "" {
+			tag_names = append(tag_names, tag_name)
+		}
+	}
+
+	return tag_names
+}
+
+// Interprets Go-style escape sequences (e.g., `\n`) in an option value, since
+// some characters (like newlines and commas) can't be passed directly. The
+// value is returned as-is if it isn't valid.
+func unescape_option_value(opt_val string) string {
+	unescaped, err := strconv.Unquote(`"` + opt_val + `"`)
+	if err != nil {
+		return opt_val
+	}
+
+	return unescaped
+}
+
 func send_code_gen_err(err error, writer io.Writer) error {
 	gen_resp := new(pluginpb.CodeGeneratorResponse)
 	err_str := err.Error()
@@ -0,0 +1,278 @@
+package render
+
+// This file contains the generators for CSV and TSV inventories of fields and
+// enum values (`outfmt=csv` and `outfmt=tsv`).
+
+// BSD 2-Clause License
+//
+// Copyright (c) 2023 Don Owens <don@regexguy.com>.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// * Redistributions of source code must retain the above copyright notice,
+//   this list of conditions and the following disclaimer.
+//
+// * Redistributions in binary form must reproduce the above copyright notice,
+//   this list of conditions and the following disclaimer in the documentation
+//   and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+import (
+	// Built-in/core modules.
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	// Third-party modules.
+	// Generated code.
+	// First-party modules.
+	docdata "github.com/cuberat/protoc-gen-docjson/internal/docdata"
+)
+
+const (
+	CSV_FIELDS_FILE      = "fields"
+	CSV_ENUM_VALUES_FILE = "enum_values"
+)
+
+// A table of rows with a fixed set of columns, followed by a column for each
+// custom option set on any of the rows.
+type inventory_table struct {
+	columns      []string
+	last_columns []string
+	rows         []*inventory_row
+}
+
+type inventory_row struct {
+	values         []string
+	last_values    []string
+	custom_options map[string]any
+}
+
+func gen_csv(
+	data *docdata.TemplateData,
+	conf *docdata.Config,
+) ([]*OutputFile, error) {
+	return gen_inventory(data, ',', ".csv")
+}
+
+func gen_tsv(
+	data *docdata.TemplateData,
+	conf *docdata.Config,
+) ([]*OutputFile, error) {
+	return gen_inventory(data, '\t', ".tsv")
+}
+
+// Writes a file with a row for each field of each message (except map
+// entries), and a file with a row for each enum value.
+func gen_inventory(
+	data *docdata.TemplateData,
+	separator rune,
+	ext string,
+) ([]*OutputFile, error) {
+	tables := []struct {
+		name  string
+		table *inventory_table
+	}{
+		{CSV_FIELDS_FILE, field_inventory(data)},
+		{CSV_ENUM_VALUES_FILE, enum_value_inventory(data)},
+	}
+
+	out_files := make([]*OutputFile, 0, len(tables))
+	for _, table := range tables {
+		content, err := table.table.render(separator)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't write %s%s: %w", table.name, ext,
+				err)
+		}
+		out_files = append(out_files,
+			&OutputFile{Name: table.name + ext, Content: content})
+	}
+
+	return out_files, nil
+}
+
+func field_inventory(data *docdata.TemplateData) *inventory_table {
+	table := &inventory_table{
+		columns: []string{"package", "message", "field", "number", "type",
+			"label", "deprecated", "description"},
+		last_columns: []string{"defined_in"},
+		rows:         make([]*inventory_row, 0),
+	}
+
+	for _, msg_name := range data.MessageList {
+		msg := data.MessageMap[msg_name]
+		if msg == nil || (msg.Options != nil && msg.Options.MapEntry) {
+			continue
+		}
+
+		for _, field := range msg.Fields {
+			table.rows = append(table.rows, &inventory_row{
+				values: []string{
+					get_file_package(data, msg.DefinedIn),
+					msg.FullName,
+					field.Name,
+					strconv.FormatInt(int64(field.FieldNumber), 10),
+					inventory_field_type(data, field),
+					field.Label,
+					strconv.FormatBool(is_deprecated(field)),
+					field.Description,
+				},
+				last_values:    []string{field.DefinedIn},
+				custom_options: field.CustomOptions,
+			})
+		}
+	}
+
+	return table
+}
+
+func enum_value_inventory(data *docdata.TemplateData) *inventory_table {
+	table := &inventory_table{
+		columns: []string{"package", "enum", "value", "number", "deprecated",
+			"description"},
+		last_columns: []string{"defined_in"},
+		rows:         make([]*inventory_row, 0),
+	}
+
+	for _, enum_name := range data.EnumList {
+		enum := data.EnumMap[enum_name]
+		if enum == nil {
+			continue
+		}
+
+		for _, value := range enum.Values {
+			table.rows = append(table.rows, &inventory_row{
+				values: []string{
+					get_file_package(data, enum.DefinedIn),
+					enum.FullName,
+					value.Name,
+					strconv.FormatInt(int64(value.Number), 10),
+					strconv.FormatBool(is_deprecated(value)),
+					value.Description,
+				},
+				last_values:    []string{enum.DefinedIn},
+				custom_options: value.CustomOptions,
+			})
+		}
+	}
+
+	return table
+}
+
+// Returns the package of the file with the given name.
+func get_file_package(data *docdata.TemplateData, file_name string) string {
+	if file_data := data.FileMap[file_name]; file_data != nil {
+		return file_data.Package
+	}
+
+	return ""
+}
+
+// Returns the type of a field: the fully-qualified name for messages and
+// enums, the scalar type otherwise, or `map<K, V>` for map fields.
+func inventory_field_type(
+	data *docdata.TemplateData,
+	field *docdata.FieldData,
+) string {
+	if entry := data.GetMapEntry(field); entry != nil {
+		return fmt.Sprintf("map<%s, %s>",
+			inventory_field_type(data, entry.Fields[0]),
+			inventory_field_type(data, entry.Fields[1]))
+	}
+
+	if field.FullTypeName != "" {
+		return strings.TrimPrefix(field.FullTypeName, ".")
+	}
+
+	return field.Kind
+}
+
+// Renders the table, with a header row. Custom option columns are named
+// after the option in parentheses, as in protobuf syntax (e.g.,
+// `(sensitive)`), and sorted by name.
+func (table *inventory_table) render(separator rune) (string, error) {
+	option_names := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, row := range table.rows {
+		for name := range row.custom_options {
+			if !seen[name] {
+				seen[name] = true
+				option_names = append(option_names, name)
+			}
+		}
+	}
+	sort.Strings(option_names)
+
+	var buf strings.Builder
+	writer := csv.NewWriter(&buf)
+	writer.Comma = separator
+
+	header := make([]string, 0,
+		len(table.columns)+len(option_names)+len(table.last_columns))
+	header = append(header, table.columns...)
+	for _, name := range option_names {
+		header = append(header, "("+name+")")
+	}
+	header = append(header, table.last_columns...)
+	if err := writer.Write(header); err != nil {
+		return "", err
+	}
+
+	for _, row := range table.rows {
+		record := make([]string, 0, len(header))
+		record = append(record, row.values...)
+		for _, name := range option_names {
+			record = append(record,
+				format_option_value(row.custom_options[name]))
+		}
+		record = append(record, row.last_values...)
+		if err := writer.Write(record); err != nil {
+			return "", err
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// Formats the value of a custom option for a table cell: scalars as text,
+// and anything else (e.g., message values) as JSON. Options that aren't set
+// are empty.
+func format_option_value(val any) string {
+	switch val := val.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case []byte:
+		return string(val)
+	case bool, int32, int64, uint32, uint64, float64:
+		return fmt.Sprint(val)
+	}
+
+	encoded, err := json.Marshal(val)
+	if err != nil {
+		return fmt.Sprint(val)
+	}
+
+	return string(encoded)
+}
@@ -0,0 +1,421 @@
+package render
+
+// This file contains the code to build dependency diagrams: a package import
+// graph, a graph for each service, and a class diagram for each message.
+// They are written as Graphviz DOT (`outfmt=dot`) or Mermaid
+// (`outfmt=mermaid`).
+
+// BSD 2-Clause License
+//
+// Copyright (c) 2023 Don Owens <don@regexguy.com>.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// * Redistributions of source code must retain the above copyright notice,
+//   this list of conditions and the following disclaimer.
+//
+// * Redistributions in binary form must reproduce the above copyright notice,
+//   this list of conditions and the following disclaimer in the documentation
+//   and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+import (
+	// Built-in/core modules.
+	"fmt"
+	"path"
+	"strings"
+
+	// Third-party modules.
+
+	// Generated code.
+	// First-party modules.
+	docdata "github.com/cuberat/protoc-gen-docjson/internal/docdata"
+)
+
+const (
+	DIAGRAM_PACKAGES_NAME = "packages"
+	DIAGRAM_SERVICES_DIR  = "services"
+	DIAGRAM_MESSAGES_DIR  = "messages"
+
+	WELL_KNOWN_PACKAGE = "google.protobuf"
+	WELL_KNOWN_DIR     = "google/protobuf/"
+)
+
+// Kinds of diagram nodes.
+const (
+	NODE_PACKAGE = "package"
+	NODE_FILE    = "file"
+	NODE_SERVICE = "service"
+	NODE_METHOD  = "method"
+	NODE_MESSAGE = "message"
+	NODE_ENUM    = "enum"
+)
+
+type diagram_node struct {
+	id      string
+	label   string
+	kind    string
+	pkg     string
+	members []string
+}
+
+type diagram_edge struct {
+	from  *diagram_node
+	to    *diagram_node
+	label string
+
+	// Dashed edges are drawn to things that aren't fully known, e.g., files
+	// that weren't given to the protobuf compiler.
+	dashed bool
+}
+
+type diagram struct {
+	// Name of the output file, without the extension.
+	name  string
+	title string
+
+	// Class diagrams show the fields of messages and the values of enums.
+	class bool
+
+	// Group nodes by package.
+	cluster bool
+
+	nodes     []*diagram_node
+	node_map  map[string]*diagram_node
+	edges     []*diagram_edge
+	edge_seen map[string]bool
+}
+
+func new_diagram(name, title string, class, cluster bool) *diagram {
+	return &diagram{
+		name:      name,
+		title:     title,
+		class:     class,
+		cluster:   cluster,
+		nodes:     make([]*diagram_node, 0),
+		node_map:  make(map[string]*diagram_node),
+		edges:     make([]*diagram_edge, 0),
+		edge_seen: make(map[string]bool),
+	}
+}
+
+// Returns the node with the given key, and whether it was created.
+func (d *diagram) add_node(
+	key, label, kind, pkg string,
+) (*diagram_node, bool) {
+	if node, ok := d.node_map[key]; ok {
+		return node, false
+	}
+
+	node := &diagram_node{
+		id:    fmt.Sprintf("n%d", len(d.nodes)),
+		label: label,
+		kind:  kind,
+		pkg:   pkg,
+	}
+	d.nodes = append(d.nodes, node)
+	d.node_map[key] = node
+
+	return node, true
+}
+
+func (d *diagram) add_edge(from, to *diagram_node, label string, dashed bool) {
+	key := from.id + " " + to.id + " " + label
+	if d.edge_seen[key] {
+		return
+	}
+	d.edge_seen[key] = true
+
+	d.edges = append(d.edges, &diagram_edge{
+		from:   from,
+		to:     to,
+		label:  label,
+		dashed: dashed,
+	})
+}
+
+// Returns the packages of the nodes, in the order they're first used, and
+// the nodes in each package.
+func (d *diagram) get_clusters() ([]string, map[string][]*diagram_node) {
+	pkgs := make([]string, 0)
+	clusters := make(map[string][]*diagram_node)
+	for _, node := range d.nodes {
+		if _, ok := clusters[node.pkg]; !ok {
+			pkgs = append(pkgs, node.pkg)
+		}
+		clusters[node.pkg] = append(clusters[node.pkg], node)
+	}
+
+	return pkgs, clusters
+}
+
+type diagram_builder struct {
+	data *docdata.TemplateData
+
+	// Number of levels of field types to follow from the request and
+	// response messages of a service, or from the message of a class
+	// diagram. Zero means no limit.
+	max_depth int
+
+	exclude_well_known bool
+	cluster            bool
+}
+
+// Builds the package import graph, a graph for each service, and a class
+// diagram for each message (except map entries).
+func build_diagrams(
+	data *docdata.TemplateData,
+	conf *docdata.Config,
+) []*diagram {
+	builder := &diagram_builder{
+		data:               data,
+		max_depth:          conf.PluginOpts.DiagramDepth,
+		exclude_well_known: conf.PluginOpts.DiagramExcludeWellKnown,
+		cluster:            conf.PluginOpts.DiagramCluster,
+	}
+
+	diagrams := []*diagram{builder.package_graph()}
+	for _, svc_name := range data.ServiceList {
+		diagrams = append(diagrams,
+			builder.service_graph(data.ServiceMap[svc_name]))
+	}
+	for _, msg_name := range data.MessageList {
+		msg := data.MessageMap[msg_name]
+		if msg.Options != nil && msg.Options.MapEntry {
+			continue
+		}
+		diagrams = append(diagrams, builder.message_diagram(msg))
+	}
+
+	return diagrams
+}
+
+// Returns the graph of packages importing other packages, from the
+// dependencies of the files in each package. Imported files that weren't
+// given to the protobuf compiler are shown as files, since their packages
+// are unknown.
+func (builder *diagram_builder) package_graph() *diagram {
+	d := new_diagram(DIAGRAM_PACKAGES_NAME, "Package imports", false, false)
+
+	for _, pkg_name := range builder.data.PackageList {
+		d.add_node(pkg_name, pkg_name, NODE_PACKAGE, pkg_name)
+	}
+
+	for _, file_name := range builder.data.FileList {
+		file := builder.data.FileMap[file_name]
+		from := d.node_map[file.Package]
+		if from == nil {
+			continue
+		}
+
+		for _, dep_name := range file.Dependencies {
+			dep := builder.data.FileMap[dep_name]
+			if dep == nil || dep.Package == file.Package {
+				continue
+			}
+			to, _ := d.add_node(dep.Package, dep.Package, NODE_PACKAGE,
+				dep.Package)
+			d.add_edge(from, to, "", false)
+		}
+
+		for _, dep_name := range file.ExternalDependencies {
+			if builder.exclude_well_known &&
+				strings.HasPrefix(dep_name, WELL_KNOWN_DIR) {
+				continue
+			}
+			to, _ := d.add_node("file:"+dep_name, dep_name, NODE_FILE, "")
+			d.add_edge(from, to, "", true)
+		}
+	}
+
+	return d
+}
+
+// Returns the graph of the methods of a service, their request and response
+// messages, and the types used by those messages.
+func (builder *diagram_builder) service_graph(
+	svc *docdata.ServiceData,
+) *diagram {
+	d := new_diagram(path.Join(DIAGRAM_SERVICES_DIR, svc.FullName),
+		svc.FullName, false, builder.cluster)
+
+	pkg := builder.get_element_package(svc.DefinedIn, svc.FullName)
+	svc_node, _ := d.add_node(svc.FullName, builder.node_label(svc.FullName,
+		pkg), NODE_SERVICE, pkg)
+
+	for _, method := range svc.Methods {
+		method_node, _ := d.add_node(method.FullName, method.Name,
+			NODE_METHOD, pkg)
+		d.add_edge(svc_node, method_node, "", false)
+
+		if req_node := builder.add_type(d, method.RequestFullType, 0); req_node != nil {
+			d.add_edge(method_node, req_node,
+				streaming_label("request", method.RequestStreaming), false)
+		}
+		if resp_node := builder.add_type(d, method.ResponseFullType, 0); resp_node != nil {
+			d.add_edge(method_node, resp_node,
+				streaming_label("response", method.ResponseStreaming), false)
+		}
+	}
+
+	return d
+}
+
+func streaming_label(label string, streaming bool) string {
+	if streaming {
+		return "stream " + label
+	}
+
+	return label
+}
+
+// Returns a class diagram with the fields of a message, and of the messages
+// and enums it uses.
+func (builder *diagram_builder) message_diagram(
+	msg *docdata.MessageData,
+) *diagram {
+	d := new_diagram(path.Join(DIAGRAM_MESSAGES_DIR, msg.FullName),
+		msg.FullName, true, builder.cluster)
+	builder.add_type(d, msg.FullName, 0)
+
+	return d
+}
+
+// Adds the node for a message or enum, and follows the types of the fields
+// of a message until the maximum depth is reached. Map entries are skipped
+// in favor of the types of their values. Returns nil if the type is
+// excluded.
+func (builder *diagram_builder) add_type(
+	d *diagram,
+	full_type string,
+	depth int,
+) *diagram_node {
+	full_type = strings.TrimPrefix(full_type, ".")
+	if builder.exclude_well_known &&
+		strings.HasPrefix(full_type, WELL_KNOWN_PACKAGE+".") {
+		return nil
+	}
+
+	msg := builder.data.MessageMap[full_type]
+	enum := builder.data.EnumMap[full_type]
+
+	var defined_in string
+	kind := NODE_MESSAGE
+	switch {
+	case msg != nil:
+		defined_in = msg.DefinedIn
+	case enum != nil:
+		defined_in = enum.DefinedIn
+		kind = NODE_ENUM
+	}
+
+	pkg := builder.get_element_package(defined_in, full_type)
+	node, created := d.add_node(full_type,
+		builder.node_label(full_type, pkg), kind, pkg)
+	if !created {
+		return node
+	}
+
+	if enum != nil && d.class {
+		for _, value := range enum.Values {
+			node.members = append(node.members, value.Name)
+		}
+	}
+	if msg == nil {
+		return node
+	}
+
+	if d.class {
+		for _, field := range msg.Fields {
+			node.members = append(node.members,
+				builder.field_type_name(field)+" "+field.Name)
+		}
+	}
+
+	if builder.max_depth > 0 && depth >= builder.max_depth {
+		return node
+	}
+
+	for _, field := range msg.Fields {
+		value_field := field
+		if entry := builder.data.GetMapEntry(field); entry != nil {
+			value_field = entry.Fields[1]
+		}
+
+		switch value_field.Kind {
+		case "message", "group", "enum":
+		default:
+			continue
+		}
+
+		if field_node := builder.add_type(d, value_field.FullTypeName,
+			depth+1); field_node != nil {
+			d.add_edge(node, field_node, field.Name, false)
+		}
+	}
+
+	return node
+}
+
+// Returns the type of a field as written in a protobuf specification, e.g.,
+// `repeated Item` or `map<string, Item>`, with the short names of messages
+// and enums.
+func (builder *diagram_builder) field_type_name(
+	field *docdata.FieldData,
+) string {
+	if entry := builder.data.GetMapEntry(field); entry != nil {
+		return fmt.Sprintf("map<%s, %s>",
+			builder.field_type_name(entry.Fields[0]),
+			builder.field_type_name(entry.Fields[1]))
+	}
+
+	type_name := field.Kind
+	switch field.Kind {
+	case "message", "group", "enum":
+		type_name = short_name(field.FullTypeName)
+	}
+
+	if field.Label == "repeated" {
+		return "repeated " + type_name
+	}
+
+	return type_name
+}
+
+// Returns the package of an element, from the file it's defined in, or from
+// its name if the file isn't known.
+func (builder *diagram_builder) get_element_package(
+	defined_in, full_name string,
+) string {
+	if file := builder.data.FileMap[defined_in]; file != nil {
+		return file.Package
+	}
+
+	if idx := strings.LastIndex(full_name, "."); idx >= 0 {
+		return full_name[:idx]
+	}
+
+	return ""
+}
+
+// Returns the label for a node: the fully-qualified name, or the name
+// relative to the package if nodes are grouped by package.
+func (builder *diagram_builder) node_label(full_name, pkg string) string {
+	if builder.cluster && pkg != "" {
+		return strings.TrimPrefix(full_name, pkg+".")
+	}
+
+	return full_name
+}
@@ -0,0 +1,606 @@
+package render
+
+// This file contains the code shared by the documentation generators (e.g.,
+// `outfmt=markdown`), which split the template data into pages and render
+// each page with a set of templates.
+
+// BSD 2-Clause License
+//
+// Copyright (c) 2023 Don Owens <don@regexguy.com>.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// * Redistributions of source code must retain the above copyright notice,
+//   this list of conditions and the following disclaimer.
+//
+// * Redistributions in binary form must reproduce the above copyright notice,
+//   this list of conditions and the following disclaimer in the documentation
+//   and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+import (
+	// Built-in/core modules.
+	"embed"
+	"fmt"
+	html_template "html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	text_template "text/template"
+
+	// Third-party modules.
+	log "github.com/sirupsen/logrus"
+
+	// Generated code.
+	// First-party modules.
+	docdata "github.com/cuberat/protoc-gen-docjson/internal/docdata"
+)
+
+// Characters that start expressions and JSX in MDX text.
+var mdx_special_re = regexp.MustCompile(`([{}<>])`)
+
+// Built-in templates, in a directory for each output format.
+//
+//go:embed templates
+var builtin_templates embed.FS
+
+// Name of the template that renders a page.
+const PAGE_TEMPLATE = "page.tmpl"
+
+// Name of the page for elements that aren't in a package.
+const DEFAULT_PAGE_NAME = "default"
+
+// Kinds of pages in a documentation tree, where each service, message, and
+// enum has its own page. Pages with all of the elements from a package or
+// file have no kind.
+const (
+	PAGE_HOME    = "home"
+	PAGE_PACKAGE = "package"
+	PAGE_SERVICE = "service"
+	PAGE_MESSAGE = "message"
+	PAGE_ENUM    = "enum"
+)
+
+// A page of generated documentation, with the elements from one package or
+// one file.
+type doc_page struct {
+	Data *docdata.TemplateData
+
+	// Package or file name.
+	Name string
+
+	// Kind of page in a documentation tree (e.g., "message"), or empty.
+	Kind string
+
+	// Title of the page in navigation, and its position among the pages in
+	// the same section, starting at 1. These are set for pages in a
+	// documentation tree.
+	Title    string
+	Position int
+
+	// Summary of the element a page is for, for page metadata.
+	Summary string
+
+	// Pages for the services, messages, and enums in the package, for
+	// package pages in a documentation tree.
+	Children []*doc_page
+
+	// Output file name, relative to the output directory.
+	FileName string
+
+	// Package the page is for. For pages split by file, this is the package
+	// of the file.
+	Package *docdata.PackageData
+
+	Description string
+
+	Files      []*docdata.FileData
+	Services   []*docdata.ServiceData
+	Messages   []*docdata.MessageData
+	Enums      []*docdata.EnumData
+	Extensions []*docdata.FileExtension
+}
+
+// A set of pages, with an index of the page each element is documented on.
+type doc_site struct {
+	data  *docdata.TemplateData
+	pages []*doc_page
+
+	// Map of fully-qualified element names to the pages they are on.
+	page_of map[string]*doc_page
+
+	// Page being rendered, for resolving relative links.
+	current *doc_page
+
+	// Pages are MDX rather than Markdown, so text must not have anything
+	// that looks like JSX or an expression.
+	mdx bool
+}
+
+// Anything that can execute a named template, i.e., a `text/template` or
+// `html/template` template set.
+type template_set interface {
+	ExecuteTemplate(writer io.Writer, name string, data any) error
+}
+
+// Builds the pages for the template data, split by package or by file
+// depending on the `split_by` plugin option. Output file names are given the
+// extension `ext`.
+func new_doc_site(
+	data *docdata.TemplateData,
+	conf *docdata.Config,
+	ext string,
+) (*doc_site, error) {
+	site := &doc_site{
+		data:    data,
+		pages:   make([]*doc_page, 0),
+		page_of: make(map[string]*doc_page),
+	}
+
+	page_map := make(map[string]*doc_page)
+	get_page := func(name, file_name string) *doc_page {
+		if page, ok := page_map[name]; ok {
+			return page
+		}
+		page := &doc_page{Data: data, Name: name, FileName: file_name}
+		page_map[name] = page
+		site.pages = append(site.pages, page)
+		return page
+	}
+
+	split_by := conf.PluginOpts.SplitBy
+	for _, file_name := range data.FileList {
+		file_data := data.FileMap[file_name]
+
+		var page *doc_page
+		switch split_by {
+		case "", "package":
+			page_name := file_data.Package
+			if page_name == "" {
+				page_name = DEFAULT_PAGE_NAME
+			}
+			page = get_page(page_name, page_name+ext)
+		case "file":
+			page = get_page(file_name,
+				strings.TrimSuffix(file_name, path.Ext(file_name))+ext)
+			if file_data.PackageDecl != nil {
+				page.Description = file_data.PackageDecl.Description
+			}
+		default:
+			return nil, fmt.Errorf("invalid split_by %q: expected package "+
+				"or file", split_by)
+		}
+
+		page.Package = data.PackageMap[file_data.Package]
+		if split_by != "file" && page.Package != nil {
+			page.Description = page.Package.Description
+		}
+
+		page.Files = append(page.Files, file_data)
+		page.Services = append(page.Services, file_data.Services...)
+		page.Messages = append_messages(page.Messages, file_data.Messages)
+		page.Enums = append(page.Enums, file_data.Enums...)
+		for _, msg := range file_data.Messages {
+			page.Enums = append_nested_enums(page.Enums, msg)
+		}
+		page.Extensions = append(page.Extensions, file_data.Extensions...)
+	}
+
+	for _, page := range site.pages {
+		site.index_page(page)
+	}
+
+	return site, nil
+}
+
+// Appends the messages and their nested messages, in declaration order.
+// Map entries are left out, since they are documented as part of the map
+// fields.
+func append_messages(
+	list []*docdata.MessageData,
+	messages []*docdata.MessageData,
+) []*docdata.MessageData {
+	for _, msg := range messages {
+		if msg.Options != nil && msg.Options.MapEntry {
+			continue
+		}
+		list = append(list, msg)
+		list = append_messages(list, msg.NestedMessages)
+	}
+
+	return list
+}
+
+func append_nested_enums(
+	list []*docdata.EnumData,
+	msg *docdata.MessageData,
+) []*docdata.EnumData {
+	list = append(list, msg.Enums...)
+	for _, nested := range msg.NestedMessages {
+		list = append_nested_enums(list, nested)
+	}
+
+	return list
+}
+
+// Records the page for each element on the page.
+func (site *doc_site) index_page(page *doc_page) {
+	for _, svc := range page.Services {
+		site.page_of[svc.FullName] = page
+		for _, method := range svc.Methods {
+			site.page_of[method.FullName] = page
+		}
+	}
+	for _, msg := range page.Messages {
+		site.page_of[msg.FullName] = page
+		for _, field := range msg.Fields {
+			site.page_of[field.FullName] = page
+		}
+	}
+	for _, enum := range page.Enums {
+		site.page_of[enum.FullName] = page
+		for _, value := range enum.Values {
+			site.page_of[enum.FullName+"."+value.Name] = page
+		}
+	}
+	for _, ext := range page.Extensions {
+		site.page_of[ext.FullName] = page
+	}
+}
+
+// Returns a link to the documentation for the element with the given
+// fully-qualified name, relative to the page being rendered, or an empty
+// string if the element isn't documented (e.g., scalar types and types from
+// files that weren't provided to the protobuf compiler).
+func (site *doc_site) ref(name string) string {
+	name = strings.TrimPrefix(name, ".")
+	page, ok := site.page_of[name]
+	if !ok {
+		return ""
+	}
+
+	if site.current != nil && page == site.current {
+		return "#" + anchor(name)
+	}
+
+	return site.relative_path(page.FileName) + "#" + anchor(name)
+}
+
+// A link to the documentation for an element, for output formats that write
+// links within a page differently than links to other pages.
+type doc_link struct {
+	// Output file name of the page the element is on, relative to the
+	// output directory.
+	Page string
+
+	Anchor string
+
+	// Whether the element is on the page being rendered.
+	Local bool
+}
+
+// Returns a link to the documentation for the element with the given
+// fully-qualified name, or nil if the element isn't documented.
+func (site *doc_site) link(name string) *doc_link {
+	name = strings.TrimPrefix(name, ".")
+	page, ok := site.page_of[name]
+	if !ok {
+		return nil
+	}
+
+	return &doc_link{
+		Page:   page.FileName,
+		Anchor: anchor(name),
+		Local:  site.current != nil && page == site.current,
+	}
+}
+
+// Returns the path to the top of the output directory from the page being
+// rendered, e.g., `../` for a page in a subdirectory. This is empty for pages
+// at the top.
+func (site *doc_site) root() string {
+	if site.current == nil {
+		return ""
+	}
+
+	dir := path.Dir(site.current.FileName)
+	if dir == "." {
+		return ""
+	}
+
+	return strings.Repeat("../", strings.Count(dir, "/")+1)
+}
+
+// Returns the path to `file_name` relative to the page being rendered.
+func (site *doc_site) relative_path(file_name string) string {
+	if site.current == nil {
+		return file_name
+	}
+
+	rel_path, err := filepath.Rel(path.Dir(site.current.FileName), file_name)
+	if err != nil {
+		return file_name
+	}
+
+	return filepath.ToSlash(rel_path)
+}
+
+// Returns the functions available to the page templates, in addition to the
+// ones from `new_func_map()`.
+func (site *doc_site) func_map() map[string]any {
+	funcs := new_func_map(site.data)
+	funcs["ref"] = site.ref
+	funcs["link"] = site.link
+	funcs["relative_path"] = site.relative_path
+	funcs["root"] = site.root
+	funcs["current_page"] = func() *doc_page { return site.current }
+	funcs["pages"] = func() []*doc_page { return site.pages }
+	funcs["local_name"] = site.local_name
+	funcs["is_map"] = site.is_map
+	funcs["map_key"] = site.map_key
+	funcs["map_value"] = site.map_value
+	funcs["heading_marker"] = site.heading_marker
+	funcs["md_text"] = site.md_text
+
+	return funcs
+}
+
+// Returns the name of an element relative to its package, e.g.,
+// `Outer.Inner` for `Foo.V1.Outer.Inner`.
+func (site *doc_site) local_name(name string) string {
+	name = strings.TrimPrefix(name, ".")
+	page := site.page_of[name]
+	if page == nil || page.Package == nil || page.Package.Name == "" {
+		return name
+	}
+
+	return strings.TrimPrefix(name, page.Package.Name+".")
+}
+
+// Returns the Markdown heading marker for an element: a top-level heading on
+// the element's own page in a documentation tree, or a third-level heading
+// on a page with the elements from a package or file.
+func (site *doc_site) heading_marker() string {
+	if site.current != nil {
+		switch site.current.Kind {
+		case PAGE_SERVICE, PAGE_MESSAGE, PAGE_ENUM:
+			return "#"
+		}
+	}
+
+	return "###"
+}
+
+// Formats description text for a Markdown page. For MDX pages, braces and
+// angle brackets outside of code spans and fenced code blocks are escaped,
+// since MDX would parse them as expressions and JSX.
+func (site *doc_site) md_text(text string) string {
+	if !site.mdx {
+		return text
+	}
+
+	lines := strings.Split(text, "\n")
+	fence := ""
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " ")
+		if fence != "" {
+			// The fence is closed by a line with at least as many of the
+			// same characters.
+			if strings.HasPrefix(trimmed, fence) &&
+				strings.Trim(trimmed, fence[:1]+" ") == "" {
+				fence = ""
+			}
+			continue
+		}
+
+		if marker := get_fence_marker(trimmed); marker != "" {
+			fence = marker
+			continue
+		}
+
+		lines[i] = mdx_escape(line)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Returns the fence (e.g., "```" or "~~~~") that opens a fenced code block
+// on the given line, or an empty string if the line doesn't open one.
+func get_fence_marker(line string) string {
+	for _, fence_char := range []string{"`", "~"} {
+		marker := line[:len(line)-len(strings.TrimLeft(line, fence_char))]
+		if len(marker) >= 3 {
+			return marker
+		}
+	}
+
+	return ""
+}
+
+// Escapes braces and angle brackets outside of code spans in a line of text.
+func mdx_escape(line string) string {
+	var builder strings.Builder
+	last := 0
+	for _, span := range code_span_re.FindAllStringIndex(line, -1) {
+		builder.WriteString(mdx_special_re.ReplaceAllString(line[last:span[0]],
+			`\$1`))
+		builder.WriteString(line[span[0]:span[1]])
+		last = span[1]
+	}
+	builder.WriteString(mdx_special_re.ReplaceAllString(line[last:], `\$1`))
+
+	return builder.String()
+}
+
+// Returns true if the field is a map.
+func (site *doc_site) is_map(field *docdata.FieldData) bool {
+	return site.map_entry(field) != nil
+}
+
+// Returns the key field of the entry message for a map field, or nil if the
+// field isn't a map.
+func (site *doc_site) map_key(field *docdata.FieldData) *docdata.FieldData {
+	if entry := site.map_entry(field); entry != nil {
+		return entry.Fields[0]
+	}
+
+	return nil
+}
+
+// Returns the value field of the entry message for a map field, or nil if
+// the field isn't a map.
+func (site *doc_site) map_value(field *docdata.FieldData) *docdata.FieldData {
+	if entry := site.map_entry(field); entry != nil {
+		return entry.Fields[1]
+	}
+
+	return nil
+}
+
+func (site *doc_site) map_entry(field *docdata.FieldData) *docdata.MessageData {
+	return site.data.GetMapEntry(field)
+}
+
+// Renders each page of the site with the page template from `tmpl_set`.
+func (site *doc_site) render_pages(
+	tmpl_set template_set,
+) ([]*OutputFile, error) {
+	out_files := make([]*OutputFile, 0, len(site.pages))
+	for _, page := range site.pages {
+		content, err := site.render_template(tmpl_set, PAGE_TEMPLATE, page,
+			page)
+		if err != nil {
+			return nil, err
+		}
+		out_files = append(out_files,
+			&OutputFile{Name: page.FileName, Content: content})
+	}
+
+	return out_files, nil
+}
+
+// Renders the named template with `data`. Links are relative to `current`,
+// or to the top of the output directory if `current` is nil.
+func (site *doc_site) render_template(
+	tmpl_set template_set,
+	tmpl_name string,
+	current *doc_page,
+	data any,
+) (string, error) {
+	site.current = current
+	defer func() { site.current = nil }()
+
+	buffer := new(strings.Builder)
+	if err := tmpl_set.ExecuteTemplate(buffer, tmpl_name, data); err != nil {
+		return "", fmt.Errorf("couldn't render %s: %w", tmpl_name, err)
+	}
+
+	return buffer.String(), nil
+}
+
+// Parses the built-in templates for the given formats, in order, so that
+// templates for later formats replace the ones with the same name for
+// earlier formats (e.g., a site layout reusing the Markdown templates). Any
+// of them can be replaced by a template with the same name in the directory
+// given by the `template_dir` plugin option.
+func (site *doc_site) load_templates(
+	conf *docdata.Config,
+	is_html bool,
+	formats ...string,
+) (template_set, error) {
+	funcs := site.func_map()
+
+	sources := make(map[string]string)
+	for _, format := range formats {
+		format_sources, err := read_template_dir(builtin_templates,
+			path.Join("templates", format))
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read built-in templates: %w",
+				err)
+		}
+		for name, content := range format_sources {
+			sources[name] = content
+		}
+	}
+
+	if template_dir := conf.PluginOpts.TemplateDir; template_dir != "" {
+		overrides, err := read_template_dir(os.DirFS(template_dir), ".")
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read template_dir: %w", err)
+		}
+		for name, content := range overrides {
+			log.Debugf("using template %s from %s", name, template_dir)
+			sources[name] = content
+		}
+	}
+
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if is_html {
+		// Descriptions rendered from Markdown by the `markdown` plugin
+		// option are already HTML.
+		funcs["safe_html"] = func(text string) html_template.HTML {
+			return html_template.HTML(text)
+		}
+
+		tmpl_set := html_template.New(formats[0]).Funcs(funcs)
+		for _, name := range names {
+			if _, err := tmpl_set.New(name).Parse(sources[name]); err != nil {
+				return nil, fmt.Errorf("couldn't parse template %s: %w",
+					name, err)
+			}
+		}
+		return tmpl_set, nil
+	}
+
+	tmpl_set := text_template.New(formats[0]).Funcs(funcs)
+	for _, name := range names {
+		if _, err := tmpl_set.New(name).Parse(sources[name]); err != nil {
+			return nil, fmt.Errorf("couldn't parse template %s: %w", name,
+				err)
+		}
+	}
+
+	return tmpl_set, nil
+}
+
+// Returns the contents of the `*.tmpl` files in a directory, keyed by file
+// name.
+func read_template_dir(fsys fs.FS, dir string) (map[string]string, error) {
+	matches, err := fs.Glob(fsys, path.Join(dir, "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+
+	sources := make(map[string]string, len(matches))
+	for _, match := range matches {
+		content, err := fs.ReadFile(fsys, match)
+		if err != nil {
+			return nil, err
+		}
+		sources[path.Base(match)] = string(content)
+	}
+
+	return sources, nil
+}
@@ -0,0 +1,219 @@
+package render
+
+// This file contains the code to lay out a documentation tree, where each
+// service, message, and enum has its own page, for documentation site
+// generators such as MkDocs and Docusaurus.
+
+// BSD 2-Clause License
+//
+// Copyright (c) 2023 Don Owens <don@regexguy.com>.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// * Redistributions of source code must retain the above copyright notice,
+//   this list of conditions and the following disclaimer.
+//
+// * Redistributions in binary form must reproduce the above copyright notice,
+//   this list of conditions and the following disclaimer in the documentation
+//   and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+import (
+	// Built-in/core modules.
+	"path"
+	"strings"
+
+	// Third-party modules.
+
+	// Generated code.
+	// First-party modules.
+	docdata "github.com/cuberat/protoc-gen-docjson/internal/docdata"
+)
+
+const (
+	DOC_TREE_INDEX     = "index.md"
+	DOC_TREE_PAGE_EXT  = ".md"
+	DOC_TREE_SERVICES  = "services"
+	DOC_TREE_MESSAGES  = "messages"
+	DOC_TREE_ENUMS     = "enums"
+	DEFAULT_SITE_TITLE = "API Reference"
+)
+
+// Section directories in a package, in the order they're shown, with their
+// titles.
+var doc_tree_sections = []struct {
+	Kind  string
+	Dir   string
+	Title string
+}{
+	{PAGE_SERVICE, DOC_TREE_SERVICES, "Services"},
+	{PAGE_MESSAGE, DOC_TREE_MESSAGES, "Messages"},
+	{PAGE_ENUM, DOC_TREE_ENUMS, "Enums"},
+}
+
+// Builds a documentation tree under the directory `root` (which is empty or
+// ends with a slash): a home page (`index.md`), a page for each package
+// (`<package>/index.md`), and a page for each service, message, and enum in
+// the package (e.g., `<package>/messages/<name>.md`). Extensions are
+// documented on the package pages. If `mdx` is true, text is escaped for
+// MDX.
+func new_doc_tree(
+	data *docdata.TemplateData,
+	conf *docdata.Config,
+	root string,
+	mdx bool,
+) *doc_site {
+	site := &doc_site{
+		data:    data,
+		pages:   make([]*doc_page, 0),
+		page_of: make(map[string]*doc_page),
+		mdx:     mdx,
+	}
+
+	title := conf.PluginOpts.APITitle
+	if title == "" {
+		title = DEFAULT_SITE_TITLE
+	}
+	site.pages = append(site.pages, &doc_page{
+		Data:     data,
+		Name:     title,
+		FileName: root + DOC_TREE_INDEX,
+		Kind:     PAGE_HOME,
+		Title:    title,
+	})
+
+	pkg_pages := make([]*doc_page, 0)
+	pkg_page_map := make(map[string]*doc_page)
+	for _, file_name := range data.FileList {
+		file_data := data.FileMap[file_name]
+		pkg_name := file_data.Package
+		if pkg_name == "" {
+			pkg_name = DEFAULT_PAGE_NAME
+		}
+
+		pkg_page, ok := pkg_page_map[pkg_name]
+		if !ok {
+			pkg_page = &doc_page{
+				Data:     data,
+				Name:     pkg_name,
+				FileName: path.Join(root, pkg_name, DOC_TREE_INDEX),
+				Kind:     PAGE_PACKAGE,
+				Title:    pkg_name,
+				Position: len(pkg_pages) + 1,
+				Package:  data.PackageMap[file_data.Package],
+			}
+			if pkg_page.Package != nil {
+				pkg_page.Description = pkg_page.Package.Description
+			}
+			pkg_page_map[pkg_name] = pkg_page
+			pkg_pages = append(pkg_pages, pkg_page)
+		}
+
+		pkg_page.Files = append(pkg_page.Files, file_data)
+		pkg_page.Services = append(pkg_page.Services, file_data.Services...)
+		pkg_page.Messages = append_messages(pkg_page.Messages,
+			file_data.Messages)
+		pkg_page.Enums = append(pkg_page.Enums, file_data.Enums...)
+		for _, msg := range file_data.Messages {
+			pkg_page.Enums = append_nested_enums(pkg_page.Enums, msg)
+		}
+		pkg_page.Extensions = append(pkg_page.Extensions,
+			file_data.Extensions...)
+	}
+
+	for _, pkg_page := range pkg_pages {
+		site.pages = append(site.pages, pkg_page)
+		for _, ext := range pkg_page.Extensions {
+			site.page_of[ext.FullName] = pkg_page
+		}
+
+		add_child := func(kind, dir, full_name, summary string) *doc_page {
+			local_name := full_name
+			if pkg_page.Package != nil && pkg_page.Package.Name != "" {
+				local_name = strings.TrimPrefix(full_name,
+					pkg_page.Package.Name+".")
+			}
+
+			position := 1
+			for _, child := range pkg_page.Children {
+				if child.Kind == kind {
+					position++
+				}
+			}
+
+			page := &doc_page{
+				Data: data,
+				Name: full_name,
+				FileName: path.Join(path.Dir(pkg_page.FileName), dir,
+					local_name+DOC_TREE_PAGE_EXT),
+				Kind:     kind,
+				Title:    local_name,
+				Position: position,
+				Summary:  summary,
+				Package:  pkg_page.Package,
+				Files:    pkg_page.Files,
+			}
+			pkg_page.Children = append(pkg_page.Children, page)
+			site.pages = append(site.pages, page)
+			return page
+		}
+
+		for _, svc := range pkg_page.Services {
+			page := add_child(PAGE_SERVICE, DOC_TREE_SERVICES, svc.FullName,
+				svc.Summary)
+			page.Services = []*docdata.ServiceData{svc}
+			site.index_page(page)
+		}
+		for _, msg := range pkg_page.Messages {
+			page := add_child(PAGE_MESSAGE, DOC_TREE_MESSAGES, msg.FullName,
+				msg.Summary)
+			page.Messages = []*docdata.MessageData{msg}
+			site.index_page(page)
+		}
+		for _, enum := range pkg_page.Enums {
+			page := add_child(PAGE_ENUM, DOC_TREE_ENUMS, enum.FullName,
+				enum.Summary)
+			page.Enums = []*docdata.EnumData{enum}
+			site.index_page(page)
+		}
+	}
+
+	return site
+}
+
+// Returns the pages in the package with the given kind, e.g., the message
+// pages.
+func (page *doc_page) children_of_kind(kind string) []*doc_page {
+	children := make([]*doc_page, 0)
+	for _, child := range page.Children {
+		if child.Kind == kind {
+			children = append(children, child)
+		}
+	}
+
+	return children
+}
+
+// Returns the package pages in a documentation tree.
+func (site *doc_site) package_pages() []*doc_page {
+	pkg_pages := make([]*doc_page, 0)
+	for _, page := range site.pages {
+		if page.Kind == PAGE_PACKAGE {
+			pkg_pages = append(pkg_pages, page)
+		}
+	}
+
+	return pkg_pages
+}
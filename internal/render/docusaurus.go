@@ -0,0 +1,210 @@
+package render
+
+// This file contains the generator for Docusaurus documentation
+// (`outfmt=docusaurus`).
+
+// BSD 2-Clause License
+//
+// Copyright (c) 2023 Don Owens <don@regexguy.com>.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// * Redistributions of source code must retain the above copyright notice,
+//   this list of conditions and the following disclaimer.
+//
+// * Redistributions in binary form must reproduce the above copyright notice,
+//   this list of conditions and the following disclaimer in the documentation
+//   and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+import (
+	// Built-in/core modules.
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	// Third-party modules.
+
+	// Generated code.
+	// First-party modules.
+	docdata "github.com/cuberat/protoc-gen-docjson/internal/docdata"
+)
+
+const (
+	DOCUSAURUS_CATEGORY_FILE = "_category_.json"
+	DOCUSAURUS_SIDEBARS_FILE = "sidebars.js"
+	DOCUSAURUS_SIDEBAR_ID    = "apiSidebar"
+)
+
+type docusaurus_category struct {
+	Label    string           `json:"label"`
+	Position int              `json:"position,omitempty"`
+	Link     *docusaurus_link `json:"link,omitempty"`
+}
+
+type docusaurus_link struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// An item in a sidebar defined in `sidebars.js`: a category, or the ID of a
+// doc.
+type docusaurus_sidebar_category struct {
+	Type  string           `json:"type"`
+	Label string           `json:"label"`
+	Link  *docusaurus_link `json:"link,omitempty"`
+	Items []any            `json:"items"`
+}
+
+// Writes a documentation tree for the docs directory of a Docusaurus site.
+// The pages are MDX with front matter giving their titles and positions in
+// the sidebar. The sidebar is autogenerated from `_category_.json` files in
+// each directory, or, with the `docusaurus_sidebars` plugin option, defined
+// in `sidebars.js`.
+func gen_docusaurus(
+	data *docdata.TemplateData,
+	conf *docdata.Config,
+) ([]*OutputFile, error) {
+	site := new_doc_tree(data, conf, "", true)
+
+	tmpl_set, err := site.load_templates(conf, false, "markdown", "doctree",
+		"docusaurus")
+	if err != nil {
+		return nil, err
+	}
+
+	out_files, err := site.render_pages(tmpl_set)
+	if err != nil {
+		return nil, err
+	}
+
+	var nav_files []*OutputFile
+	if conf.PluginOpts.DocusaurusSidebars {
+		nav_files, err = get_docusaurus_sidebars(site)
+	} else {
+		nav_files, err = get_docusaurus_categories(site)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return append(out_files, nav_files...), nil
+}
+
+// Returns the ID Docusaurus gives a doc: its path without the extension.
+func docusaurus_doc_id(page *doc_page) string {
+	return strings.TrimSuffix(page.FileName, path.Ext(page.FileName))
+}
+
+// Returns a `_category_.json` file for each package directory, linked to
+// the package page, and for each section directory in a package.
+func get_docusaurus_categories(site *doc_site) ([]*OutputFile, error) {
+	out_files := make([]*OutputFile, 0)
+	add_category := func(dir string, category *docusaurus_category) error {
+		json_bytes, err := json.MarshalIndent(category, "", "  ")
+		if err != nil {
+			return fmt.Errorf("couldn't marshal category %s: %w",
+				category.Label, err)
+		}
+		out_files = append(out_files, &OutputFile{
+			Name:    path.Join(dir, DOCUSAURUS_CATEGORY_FILE),
+			Content: string(json_bytes) + "\n",
+		})
+		return nil
+	}
+
+	for _, pkg_page := range site.package_pages() {
+		pkg_dir := path.Dir(pkg_page.FileName)
+		err := add_category(pkg_dir, &docusaurus_category{
+			Label:    pkg_page.Title,
+			Position: pkg_page.Position,
+			Link: &docusaurus_link{
+				Type: "doc",
+				ID:   docusaurus_doc_id(pkg_page),
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for i, section := range doc_tree_sections {
+			if len(pkg_page.children_of_kind(section.Kind)) == 0 {
+				continue
+			}
+			err := add_category(path.Join(pkg_dir, section.Dir),
+				&docusaurus_category{Label: section.Title, Position: i + 1})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return out_files, nil
+}
+
+// Returns `sidebars.js`, defining a sidebar with the home page and a
+// category for each package, with categories for its services, messages,
+// and enums.
+func get_docusaurus_sidebars(site *doc_site) ([]*OutputFile, error) {
+	items := []any{docusaurus_doc_id(site.pages[0])}
+	for _, pkg_page := range site.package_pages() {
+		pkg_items := make([]any, 0)
+		for _, section := range doc_tree_sections {
+			children := pkg_page.children_of_kind(section.Kind)
+			if len(children) == 0 {
+				continue
+			}
+
+			section_items := make([]any, 0, len(children))
+			for _, child := range children {
+				section_items = append(section_items,
+					docusaurus_doc_id(child))
+			}
+			pkg_items = append(pkg_items, &docusaurus_sidebar_category{
+				Type:  "category",
+				Label: section.Title,
+				Items: section_items,
+			})
+		}
+
+		items = append(items, &docusaurus_sidebar_category{
+			Type:  "category",
+			Label: pkg_page.Title,
+			Link: &docusaurus_link{
+				Type: "doc",
+				ID:   docusaurus_doc_id(pkg_page),
+			},
+			Items: pkg_items,
+		})
+	}
+
+	sidebars := map[string]any{DOCUSAURUS_SIDEBAR_ID: items}
+	json_bytes, err := json.MarshalIndent(sidebars, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("couldn't marshal %s: %w",
+			DOCUSAURUS_SIDEBARS_FILE, err)
+	}
+
+	content := "// Sidebars for the API documentation, generated by " +
+		"protoc-gen-docjson.\n\n" +
+		"/** @type {import('@docusaurus/plugin-content-docs')." +
+		"SidebarsConfig} */\n" +
+		"const sidebars = " + string(json_bytes) + ";\n\n" +
+		"module.exports = sidebars;\n"
+
+	return []*OutputFile{{Name: DOCUSAURUS_SIDEBARS_FILE, Content: content}},
+		nil
+}
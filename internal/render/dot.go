@@ -0,0 +1,159 @@
+package render
+
+// This file contains the generator for Graphviz DOT diagrams
+// (`outfmt=dot`).
+
+// BSD 2-Clause License
+//
+// Copyright (c) 2023 Don Owens <don@regexguy.com>.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// * Redistributions of source code must retain the above copyright notice,
+//   this list of conditions and the following disclaimer.
+//
+// * Redistributions in binary form must reproduce the above copyright notice,
+//   this list of conditions and the following disclaimer in the documentation
+//   and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+import (
+	// Built-in/core modules.
+	"fmt"
+	"strings"
+
+	// Third-party modules.
+
+	// Generated code.
+	// First-party modules.
+	docdata "github.com/cuberat/protoc-gen-docjson/internal/docdata"
+)
+
+const DOT_EXT = ".dot"
+
+// Node shapes, by kind of node.
+var dot_shapes = map[string]string{
+	NODE_PACKAGE: "folder",
+	NODE_FILE:    "note",
+	NODE_SERVICE: "component",
+	NODE_METHOD:  "ellipse",
+	NODE_MESSAGE: "box",
+	NODE_ENUM:    "hexagon",
+}
+
+// Escapes characters that are special in record labels.
+var dot_record_replacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`,
+	"{", `\{`, "}", `\}`, "|", `\|`, "<", `\<`, ">", `\>`)
+
+func gen_dot(
+	data *docdata.TemplateData,
+	conf *docdata.Config,
+) ([]*OutputFile, error) {
+	diagrams := build_diagrams(data, conf)
+	out_files := make([]*OutputFile, 0, len(diagrams))
+	for _, d := range diagrams {
+		out_files = append(out_files, &OutputFile{
+			Name:    d.name + DOT_EXT,
+			Content: render_dot(d),
+		})
+	}
+
+	return out_files, nil
+}
+
+func render_dot(d *diagram) string {
+	var buf strings.Builder
+
+	fmt.Fprintf(&buf, "digraph %s {\n", dot_quote(d.title))
+	buf.WriteString("    rankdir=LR;\n")
+	buf.WriteString("    node [fontname=\"Helvetica\"];\n")
+	buf.WriteString("    edge [fontname=\"Helvetica\", fontsize=10];\n")
+
+	if d.cluster {
+		pkgs, clusters := d.get_clusters()
+		for i, pkg := range pkgs {
+			if pkg == "" {
+				write_dot_nodes(&buf, d, clusters[pkg], "    ")
+				continue
+			}
+
+			fmt.Fprintf(&buf, "    subgraph cluster_%d {\n", i)
+			fmt.Fprintf(&buf, "        label=%s;\n", dot_quote(pkg))
+			write_dot_nodes(&buf, d, clusters[pkg], "        ")
+			buf.WriteString("    }\n")
+		}
+	} else {
+		write_dot_nodes(&buf, d, d.nodes, "    ")
+	}
+
+	for _, edge := range d.edges {
+		attrs := make([]string, 0, 2)
+		if edge.label != "" {
+			attrs = append(attrs, "label="+dot_quote(edge.label))
+		}
+		if edge.dashed {
+			attrs = append(attrs, "style=dashed")
+		}
+
+		fmt.Fprintf(&buf, "    %s -> %s", edge.from.id, edge.to.id)
+		if len(attrs) > 0 {
+			fmt.Fprintf(&buf, " [%s]", strings.Join(attrs, ", "))
+		}
+		buf.WriteString(";\n")
+	}
+
+	buf.WriteString("}\n")
+
+	return buf.String()
+}
+
+func write_dot_nodes(
+	buf *strings.Builder,
+	d *diagram,
+	nodes []*diagram_node,
+	prefix string,
+) {
+	for _, node := range nodes {
+		if d.class && (node.kind == NODE_MESSAGE || node.kind == NODE_ENUM) {
+			fmt.Fprintf(buf, "%s%s [shape=record, label=\"%s\"];\n", prefix,
+				node.id, dot_record_label(node))
+			continue
+		}
+
+		fmt.Fprintf(buf, "%s%s [shape=%s, label=%s];\n", prefix, node.id,
+			dot_shapes[node.kind], dot_quote(node.label))
+	}
+}
+
+// Returns the label of a record node: the name, followed by a left-aligned
+// line for each field or enum value.
+func dot_record_label(node *diagram_node) string {
+	title := dot_record_replacer.Replace(node.label)
+	if node.kind == NODE_ENUM {
+		title = `«enum»\n` + title
+	}
+
+	var members strings.Builder
+	for _, member := range node.members {
+		members.WriteString(dot_record_replacer.Replace(member))
+		members.WriteString(`\l`)
+	}
+
+	return "{" + title + "|" + members.String() + "}"
+}
+
+func dot_quote(text string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(text) + `"`
+}
@@ -0,0 +1,476 @@
+package render
+
+// This file contains the helper functions available to templates.
+
+// BSD 2-Clause License
+//
+// Copyright (c) 2023 Don Owens <don@regexguy.com>.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// * Redistributions of source code must retain the above copyright notice,
+//   this list of conditions and the following disclaimer.
+//
+// * Redistributions in binary form must reproduce the above copyright notice,
+//   this list of conditions and the following disclaimer in the documentation
+//   and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+import (
+	// Built-in/core modules.
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	// Third-party modules.
+	// Generated code.
+	// First-party modules.
+	docdata "github.com/cuberat/protoc-gen-docjson/internal/docdata"
+)
+
+// Characters that have a special meaning in Markdown text.
+var markdown_special_re = regexp.MustCompile("([\\\\`*_\\[\\]<>|#])")
+
+// Characters that start or end inline markup in reStructuredText text.
+var rst_special_re = regexp.MustCompile("([\\\\*_|`])")
+
+// Matches a Markdown code span, e.g., `name`.
+var code_span_re = regexp.MustCompile("`([^`\n]+)`")
+
+// Runs of characters that aren't allowed in anchors.
+var anchor_invalid_re = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// Returns the functions available to templates. Lookups are done in `data`.
+func new_func_map(data *docdata.TemplateData) map[string]any {
+	lookup := &element_lookup{data: data}
+
+	return map[string]any{
+		// Lookups by fully-qualified name. These return nil if the element
+		// doesn't exist.
+		"message":   lookup.message,
+		"enum":      lookup.enum,
+		"service":   lookup.service,
+		"method":    lookup.method,
+		"extension": lookup.extension,
+		"file":      lookup.file,
+		"package":   lookup.pkg,
+		"lookup":    lookup.any,
+
+		// Formatting.
+		"anchor":       anchor,
+		"indent":       indent,
+		"md_escape":    md_escape,
+		"md_cell":      md_cell,
+		"adoc_cell":    adoc_cell,
+		"rst_text":     rst_text,
+		"rst_cell":     rst_cell,
+		"rst_heading":  rst_heading,
+		"rst_title":    rst_title,
+		"rst_label":    rst_label,
+		"yaml_quote":   yaml_quote,
+		"short_name":   short_name,
+		"format_value": format_value,
+		"paragraphs":   paragraphs,
+		"deprecated":   is_deprecated,
+
+		// Sorting.
+		"sort_strings": sort_strings,
+		"sort_by":      sort_by,
+		"keys":         sorted_keys,
+
+		// Strings.
+		"join":        join,
+		"split":       strings.Split,
+		"lower":       strings.ToLower,
+		"upper":       strings.ToUpper,
+		"trim":        strings.TrimSpace,
+		"replace":     strings.ReplaceAll,
+		"contains":    strings.Contains,
+		"has_prefix":  strings.HasPrefix,
+		"has_suffix":  strings.HasSuffix,
+		"trim_prefix": strings.TrimPrefix,
+	}
+}
+
+type element_lookup struct {
+	data *docdata.TemplateData
+}
+
+func (lookup *element_lookup) message(name string) *docdata.MessageData {
+	return lookup.data.MessageMap[strings.TrimPrefix(name, ".")]
+}
+
+func (lookup *element_lookup) enum(name string) *docdata.EnumData {
+	return lookup.data.EnumMap[strings.TrimPrefix(name, ".")]
+}
+
+func (lookup *element_lookup) service(name string) *docdata.ServiceData {
+	return lookup.data.ServiceMap[strings.TrimPrefix(name, ".")]
+}
+
+func (lookup *element_lookup) method(name string) *docdata.MethodData {
+	name = strings.TrimPrefix(name, ".")
+	dot := strings.LastIndex(name, ".")
+	if dot < 0 {
+		return nil
+	}
+
+	svc := lookup.data.ServiceMap[name[:dot]]
+	if svc == nil {
+		return nil
+	}
+	for _, method := range svc.Methods {
+		if method.FullName == name {
+			return method
+		}
+	}
+
+	return nil
+}
+
+func (lookup *element_lookup) extension(name string) *docdata.FileExtension {
+	return lookup.data.ExtensionMap[strings.TrimPrefix(name, ".")]
+}
+
+func (lookup *element_lookup) file(name string) *docdata.FileData {
+	return lookup.data.FileMap[name]
+}
+
+func (lookup *element_lookup) pkg(name string) *docdata.PackageData {
+	return lookup.data.PackageMap[name]
+}
+
+// Returns the message, enum, service, method, extension, or package with the
+// given name, or nil if there isn't one.
+func (lookup *element_lookup) any(name string) any {
+	if msg := lookup.message(name); msg != nil {
+		return msg
+	}
+	if enum := lookup.enum(name); enum != nil {
+		return enum
+	}
+	if svc := lookup.service(name); svc != nil {
+		return svc
+	}
+	if method := lookup.method(name); method != nil {
+		return method
+	}
+	if ext := lookup.extension(name); ext != nil {
+		return ext
+	}
+	if pkg := lookup.pkg(name); pkg != nil {
+		return pkg
+	}
+
+	return nil
+}
+
+// Returns an anchor (e.g., for `href="#..."`) for a name. The same name
+// always gives the same anchor, e.g., `Foo.V1.Bar` gives `Foo-V1-Bar`. Case
+// is kept, since protobuf names that differ only in case (e.g., a nested
+// message `Item` and a field `item`) are distinct.
+func anchor(name string) string {
+	return strings.Trim(anchor_invalid_re.ReplaceAllString(name, "-"), "-")
+}
+
+// Indents each non-empty line of `text` by `width` spaces.
+func indent(width int, text string) string {
+	prefix := strings.Repeat(" ", width)
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = prefix + line
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Escapes the characters in `text` that have a special meaning in Markdown.
+func md_escape(text string) string {
+	return markdown_special_re.ReplaceAllString(text, `\$1`)
+}
+
+// Formats text for a Markdown table cell, escaping pipes and replacing line
+// breaks with `<br />`.
+func md_cell(text string) string {
+	text = strings.ReplaceAll(strings.TrimSpace(text), "|", `\|`)
+	return strings.ReplaceAll(text, "\n", "<br />")
+}
+
+// Formats text for an AsciiDoc table cell, escaping the cell separator.
+func adoc_cell(text string) string {
+	return strings.ReplaceAll(strings.TrimSpace(text), "|", `\|`)
+}
+
+// Escapes the characters in `text` that would start reStructuredText inline
+// markup. Markdown code spans (e.g., `name`) are turned into inline literals,
+// which use double backquotes, since single backquotes mean something else.
+func rst_text(text string) string {
+	var builder strings.Builder
+
+	last := 0
+	for _, span := range code_span_re.FindAllStringSubmatchIndex(text, -1) {
+		builder.WriteString(
+			rst_special_re.ReplaceAllString(text[last:span[0]], `\$1`))
+		builder.WriteString("``" + text[span[2]:span[3]] + "``")
+		last = span[1]
+	}
+	builder.WriteString(rst_special_re.ReplaceAllString(text[last:], `\$1`))
+
+	return builder.String()
+}
+
+// Formats text for a cell of a `list-table` directive, as written by the
+// built-in templates: continuation lines are indented to line up with the
+// first line.
+func rst_cell(text string) string {
+	lines := strings.Split(rst_text(strings.TrimSpace(text)), "\n")
+	for i := 1; i < len(lines); i++ {
+		if lines[i] != "" {
+			lines[i] = "       " + lines[i]
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Returns a reStructuredText section heading: the text underlined with
+// `char`, e.g., "-".
+func rst_heading(char, text string) string {
+	text = rst_text(text)
+	return text + "\n" + strings.Repeat(char, utf8.RuneCountInString(text))
+}
+
+// Returns a reStructuredText document title, with `=` above and below the
+// text.
+func rst_title(text string) string {
+	text = rst_text(text)
+	line := strings.Repeat("=", utf8.RuneCountInString(text))
+	return line + "\n" + text + "\n" + line
+}
+
+// Returns the label used for cross-references to an element with `:ref:`.
+// Sphinx compares labels without regard to case, so they're lower-cased.
+func rst_label(name string) string {
+	return strings.ToLower(anchor(name))
+}
+
+// Returns `text` as a double-quoted YAML string, e.g., for front matter.
+func yaml_quote(text string) string {
+	// JSON strings are valid YAML.
+	var buf strings.Builder
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(text); err != nil {
+		return `""`
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// Returns true if the element is deprecated, either with the `deprecated`
+// option or a `@deprecated` doc tag.
+func is_deprecated(elem any) bool {
+	var (
+		comments   *docdata.CommentData
+		deprecated bool
+	)
+
+	switch elem := elem.(type) {
+	case *docdata.MessageData:
+		comments = &elem.CommentData
+		deprecated = elem.Options != nil && elem.Options.Deprecated
+	case *docdata.FieldData:
+		comments = &elem.CommentData
+		deprecated = elem.Options != nil && elem.Options.Deprecated
+	case *docdata.EnumData:
+		comments = &elem.CommentData
+		deprecated = elem.Options != nil && elem.Options.Deprecated
+	case *docdata.EnumValue:
+		comments = &elem.CommentData
+		deprecated = elem.Options != nil && elem.Options.Deprecated
+	case *docdata.ServiceData:
+		comments = &elem.CommentData
+		deprecated = elem.Options != nil && elem.Options.Deprecated
+	case *docdata.MethodData:
+		comments = &elem.CommentData
+		deprecated = elem.Options != nil && elem.Options.Deprecated
+	case *docdata.FileExtension:
+		comments = &elem.CommentData
+		deprecated = elem.Options != nil && elem.Options.Deprecated
+	default:
+		return false
+	}
+
+	_, has_tag := comments.Tags["deprecated"]
+
+	return deprecated || has_tag
+}
+
+// Formats an option value for display. Strings are returned as-is, and other
+// values are formatted as JSON.
+func format_value(val any) string {
+	if str, ok := val.(string); ok {
+		return str
+	}
+
+	json_bytes, err := json.Marshal(val)
+	if err != nil {
+		return fmt.Sprint(val)
+	}
+
+	return string(json_bytes)
+}
+
+// Splits text into paragraphs, on blank lines.
+func paragraphs(text string) []string {
+	paras := make([]string, 0)
+	for _, para := range strings.Split(strings.TrimSpace(text), "\n\n") {
+		if para = strings.TrimSpace(para); para != "" {
+			paras = append(paras, para)
+		}
+	}
+
+	return paras
+}
+
+// Returns the last component of a fully-qualified name.
+func short_name(name string) string {
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// Joins a list of strings. The list comes last so that the function can be
+// used with the result of a pipeline, e.g., `{{ .List | join ", " }}`.
+func join(sep string, list []string) string {
+	return strings.Join(list, sep)
+}
+
+// Returns a sorted copy of a list of strings.
+func sort_strings(list []string) []string {
+	sorted := append([]string(nil), list...)
+	sort.Strings(sorted)
+
+	return sorted
+}
+
+// Returns the keys of a map with string keys, sorted.
+func sorted_keys(in_map any) ([]string, error) {
+	map_val := reflect.ValueOf(in_map)
+	if map_val.Kind() != reflect.Map ||
+		map_val.Type().Key().Kind() != reflect.String {
+		return nil, fmt.Errorf("keys: expected a map with string keys, got %T",
+			in_map)
+	}
+
+	keys := make([]string, 0, map_val.Len())
+	for _, key := range map_val.MapKeys() {
+		keys = append(keys, key.String())
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+// Returns a copy of a list of structs (or pointers to structs), sorted by the
+// given field. The field can be given by its Go name or JSON name, e.g.,
+// `FieldNumber` or `field_number`.
+func sort_by(field_name string, list any) (any, error) {
+	list_val := reflect.ValueOf(list)
+	if list_val.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("sort_by: expected a list, got %T", list)
+	}
+
+	sorted := reflect.MakeSlice(list_val.Type(), list_val.Len(),
+		list_val.Len())
+	reflect.Copy(sorted, list_val)
+
+	elem_type := list_val.Type().Elem()
+	if elem_type.Kind() == reflect.Pointer {
+		elem_type = elem_type.Elem()
+	}
+	if elem_type.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("sort_by: expected a list of structs, got %T",
+			list)
+	}
+
+	field_index, ok := get_field_index(elem_type, field_name)
+	if !ok {
+		return nil, fmt.Errorf("sort_by: %s has no field %q", elem_type,
+			field_name)
+	}
+
+	get_key := func(i int) reflect.Value {
+		elem := sorted.Index(i)
+		if elem.Kind() == reflect.Pointer {
+			if elem.IsNil() {
+				return reflect.Value{}
+			}
+			elem = elem.Elem()
+		}
+		return elem.FieldByIndex(field_index)
+	}
+
+	sort.SliceStable(sorted.Interface(), func(i, j int) bool {
+		return less_value(get_key(i), get_key(j))
+	})
+
+	return sorted.Interface(), nil
+}
+
+// Finds a struct field by its Go name or JSON name, including fields of
+// embedded structs.
+func get_field_index(struct_type reflect.Type, name string) ([]int, bool) {
+	if field, ok := struct_type.FieldByName(name); ok {
+		return field.Index, true
+	}
+
+	field, ok := struct_type.FieldByNameFunc(func(go_name string) bool {
+		field, _ := struct_type.FieldByName(go_name)
+		json_name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		return json_name == name
+	})
+
+	return field.Index, ok
+}
+
+// Compares two values of the same type, for sorting. Invalid values (e.g.,
+// from nil pointers) sort first.
+func less_value(a, b reflect.Value) bool {
+	if !a.IsValid() || !b.IsValid() {
+		return !a.IsValid() && b.IsValid()
+	}
+
+	switch a.Kind() {
+	case reflect.String:
+		return a.String() < b.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		return a.Int() < b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		return a.Uint() < b.Uint()
+	case reflect.Float32, reflect.Float64:
+		return a.Float() < b.Float()
+	case reflect.Bool:
+		return !a.Bool() && b.Bool()
+	}
+
+	return fmt.Sprint(a.Interface()) < fmt.Sprint(b.Interface())
+}
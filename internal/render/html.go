@@ -0,0 +1,151 @@
+package render
+
+// This file contains the generator for a static HTML documentation site
+// (`outfmt=html`).
+
+// BSD 2-Clause License
+//
+// Copyright (c) 2023 Don Owens <don@regexguy.com>.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// * Redistributions of source code must retain the above copyright notice,
+//   this list of conditions and the following disclaimer.
+//
+// * Redistributions in binary form must reproduce the above copyright notice,
+//   this list of conditions and the following disclaimer in the documentation
+//   and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+import (
+	// Built-in/core modules.
+	"encoding/json"
+	"fmt"
+
+	// Third-party modules.
+	// Generated code.
+	// First-party modules.
+	docdata "github.com/cuberat/protoc-gen-docjson/internal/docdata"
+)
+
+// Name of the template that renders the index page.
+const INDEX_TEMPLATE = "index.tmpl"
+
+// Names of the files written in addition to the pages.
+const (
+	HTML_INDEX_FILE   = "index.html"
+	SEARCH_INDEX_FILE = "search_index.js"
+)
+
+// An entry in the search index.
+type search_entry struct {
+	Name    string `json:"name"`
+	Kind    string `json:"kind"`
+	URL     string `json:"url"`
+	Summary string `json:"summary"`
+}
+
+// Writes an HTML page for each package (or file, with `split_by=file`), an
+// index page, and a search index. The pages don't use any external assets,
+// so the output directory can be served as is.
+func gen_html(
+	data *docdata.TemplateData,
+	conf *docdata.Config,
+) ([]*OutputFile, error) {
+	site, err := new_doc_site(data, conf, ".html")
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl_set, err := site.load_templates(conf, true, "html")
+	if err != nil {
+		return nil, err
+	}
+
+	out_files, err := site.render_pages(tmpl_set)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := site.render_template(tmpl_set, INDEX_TEMPLATE, nil, data)
+	if err != nil {
+		return nil, err
+	}
+	out_files = append(out_files,
+		&OutputFile{Name: HTML_INDEX_FILE, Content: index})
+
+	search_index, err := site.get_search_index()
+	if err != nil {
+		return nil, err
+	}
+	out_files = append(out_files,
+		&OutputFile{Name: SEARCH_INDEX_FILE, Content: search_index})
+
+	return out_files, nil
+}
+
+// Returns a script that sets `DOCJSON_SEARCH_INDEX` to the list of
+// documented packages, services, methods, messages, enums, and extensions.
+// A script is used rather than a JSON file so that the search works when the
+// pages are opened directly from the file system.
+func (site *doc_site) get_search_index() (string, error) {
+	data := site.data
+	entries := make([]*search_entry, 0)
+
+	add_entry := func(name, kind, summary string) {
+		url := site.ref(name)
+		if url == "" {
+			return
+		}
+		entries = append(entries, &search_entry{
+			Name:    name,
+			Kind:    kind,
+			URL:     url,
+			Summary: summary,
+		})
+	}
+
+	for _, page := range site.pages {
+		entries = append(entries, &search_entry{
+			Name:    page.Name,
+			Kind:    "page",
+			URL:     page.FileName,
+			Summary: page.Description,
+		})
+	}
+	for _, name := range data.ServiceList {
+		svc := data.ServiceMap[name]
+		add_entry(name, "service", svc.Summary)
+		for _, method := range svc.Methods {
+			add_entry(method.FullName, "method", method.Summary)
+		}
+	}
+	for _, name := range data.MessageList {
+		add_entry(name, "message", data.MessageMap[name].Summary)
+	}
+	for _, name := range data.EnumList {
+		add_entry(name, "enum", data.EnumMap[name].Summary)
+	}
+	for _, name := range data.ExtensionList {
+		add_entry(name, "extension", data.ExtensionMap[name].Summary)
+	}
+
+	json_bytes, err := json.Marshal(entries)
+	if err != nil {
+		return "", fmt.Errorf("couldn't marshal search index: %w", err)
+	}
+
+	return fmt.Sprintf("window.DOCJSON_SEARCH_INDEX = %s;\n", json_bytes), nil
+}
@@ -0,0 +1,243 @@
+package render
+
+// This file contains the code to decode the HTTP rules
+// (`google.api.http` method options) used to transcode gRPC methods to REST.
+
+// BSD 2-Clause License
+//
+// Copyright (c) 2023 Don Owens <don@regexguy.com>.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// * Redistributions of source code must retain the above copyright notice,
+//   this list of conditions and the following disclaimer.
+//
+// * Redistributions in binary form must reproduce the above copyright notice,
+//   this list of conditions and the following disclaimer in the documentation
+//   and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+import (
+	// Built-in/core modules.
+	"fmt"
+	"strconv"
+	"strings"
+
+	// Third-party modules.
+	protowire "google.golang.org/protobuf/encoding/protowire"
+
+	// Generated code.
+	// First-party modules.
+	docdata "github.com/cuberat/protoc-gen-docjson/internal/docdata"
+)
+
+// Field number of the `google.api.http` extension of
+// `google.protobuf.MethodOptions`.
+const HTTP_RULE_FIELD_NUM = 72295728
+
+// Field numbers in `google.api.HttpRule`.
+const (
+	http_rule_get                 = 2
+	http_rule_put                 = 3
+	http_rule_post                = 4
+	http_rule_delete              = 5
+	http_rule_patch               = 6
+	http_rule_body                = 7
+	http_rule_custom              = 8
+	http_rule_additional_bindings = 11
+	http_rule_response_body       = 12
+
+	custom_http_pattern_kind = 1
+	custom_http_pattern_path = 2
+)
+
+// An HTTP binding for a method.
+type http_rule struct {
+	// HTTP method, in lower case, e.g., "get".
+	Method string
+
+	// URL path template, e.g., `/v1/{name=shelves/*}`.
+	Path string
+
+	// Field of the request message that is the request body: "*" for the
+	// whole message, or empty for no body.
+	Body string
+
+	// Field of the response message that is the response body. Empty means
+	// the whole message.
+	ResponseBody string
+
+	AdditionalBindings []*http_rule
+}
+
+// Returns the HTTP rules for a method, including additional bindings, or nil
+// if the method doesn't have any. The `google.api.http` option is normally
+// unknown to the plugin, so it is decoded from the raw options.
+func get_http_rules(method *docdata.MethodData) ([]*http_rule, error) {
+	if method.Options == nil {
+		return nil, nil
+	}
+
+	var (
+		rule *http_rule
+		err  error
+	)
+
+	raw_opts := method.Options.RawOptions
+	if raw, ok := raw_opts[strconv.Itoa(HTTP_RULE_FIELD_NUM)]; ok {
+		raw_bytes, ok := raw.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("unexpected value %v for google.api.http "+
+				"option", raw)
+		}
+		rule, err = decode_http_rule(raw_bytes)
+	} else if decoded, ok := raw_opts["(google.api.http)"].(map[string]any); ok {
+		rule, err = get_http_rule_from_map(decoded)
+	}
+	if err != nil || rule == nil {
+		return nil, err
+	}
+
+	rules := []*http_rule{rule}
+	rules = append(rules, rule.AdditionalBindings...)
+
+	return rules, nil
+}
+
+func decode_http_rule(raw []byte) (*http_rule, error) {
+	rule := new(http_rule)
+
+	for len(raw) > 0 {
+		field_num, wire_type, tag_len := protowire.ConsumeTag(raw)
+		if tag_len < 0 {
+			return nil, fmt.Errorf("couldn't decode HTTP rule: %w",
+				protowire.ParseError(tag_len))
+		}
+		raw = raw[tag_len:]
+
+		if wire_type != protowire.BytesType {
+			val_len := protowire.ConsumeFieldValue(field_num, wire_type, raw)
+			if val_len < 0 {
+				return nil, fmt.Errorf("couldn't decode HTTP rule: %w",
+					protowire.ParseError(val_len))
+			}
+			raw = raw[val_len:]
+			continue
+		}
+
+		val, val_len := protowire.ConsumeBytes(raw)
+		if val_len < 0 {
+			return nil, fmt.Errorf("couldn't decode HTTP rule: %w",
+				protowire.ParseError(val_len))
+		}
+		raw = raw[val_len:]
+
+		switch field_num {
+		case http_rule_get:
+			rule.Method, rule.Path = "get", string(val)
+		case http_rule_put:
+			rule.Method, rule.Path = "put", string(val)
+		case http_rule_post:
+			rule.Method, rule.Path = "post", string(val)
+		case http_rule_delete:
+			rule.Method, rule.Path = "delete", string(val)
+		case http_rule_patch:
+			rule.Method, rule.Path = "patch", string(val)
+		case http_rule_body:
+			rule.Body = string(val)
+		case http_rule_response_body:
+			rule.ResponseBody = string(val)
+		case http_rule_custom:
+			kind, custom_path, err := decode_custom_http_pattern(val)
+			if err != nil {
+				return nil, err
+			}
+			rule.Method, rule.Path = strings.ToLower(kind), custom_path
+		case http_rule_additional_bindings:
+			binding, err := decode_http_rule(val)
+			if err != nil {
+				return nil, err
+			}
+			rule.AdditionalBindings =
+				append(rule.AdditionalBindings, binding)
+		}
+	}
+
+	return rule, nil
+}
+
+func decode_custom_http_pattern(raw []byte) (string, string, error) {
+	kind, custom_path := "", ""
+	for len(raw) > 0 {
+		field_num, wire_type, tag_len := protowire.ConsumeTag(raw)
+		if tag_len < 0 {
+			return "", "", fmt.Errorf("couldn't decode custom HTTP pattern: %w",
+				protowire.ParseError(tag_len))
+		}
+		raw = raw[tag_len:]
+
+		val_len := protowire.ConsumeFieldValue(field_num, wire_type, raw)
+		if val_len < 0 {
+			return "", "", fmt.Errorf("couldn't decode custom HTTP pattern: %w",
+				protowire.ParseError(val_len))
+		}
+		if wire_type == protowire.BytesType {
+			val, _ := protowire.ConsumeBytes(raw)
+			switch field_num {
+			case custom_http_pattern_kind:
+				kind = string(val)
+			case custom_http_pattern_path:
+				custom_path = string(val)
+			}
+		}
+		raw = raw[val_len:]
+	}
+
+	return kind, custom_path, nil
+}
+
+// Returns the HTTP rule from the decoded `google.api.http` option, for when
+// the plugin knows the definition of the option.
+func get_http_rule_from_map(decoded map[string]any) (*http_rule, error) {
+	rule := new(http_rule)
+	for _, method := range []string{"get", "put", "post", "delete", "patch"} {
+		if rule_path, ok := decoded[method].(string); ok {
+			rule.Method, rule.Path = method, rule_path
+		}
+	}
+	if custom, ok := decoded["custom"].(map[string]any); ok {
+		kind, _ := custom["kind"].(string)
+		rule.Path, _ = custom["path"].(string)
+		rule.Method = strings.ToLower(kind)
+	}
+	rule.Body, _ = decoded["body"].(string)
+	rule.ResponseBody, _ = decoded["response_body"].(string)
+
+	bindings, _ := decoded["additional_bindings"].([]any)
+	for _, binding := range bindings {
+		binding_map, ok := binding.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("unexpected additional binding %v", binding)
+		}
+		decoded_binding, err := get_http_rule_from_map(binding_map)
+		if err != nil {
+			return nil, err
+		}
+		rule.AdditionalBindings =
+			append(rule.AdditionalBindings, decoded_binding)
+	}
+
+	return rule, nil
+}
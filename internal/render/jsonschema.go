@@ -0,0 +1,128 @@
+package render
+
+// This file contains the generator for JSON Schema documents describing the
+// protobuf JSON encoding of messages (`outfmt=jsonschema`).
+
+// BSD 2-Clause License
+//
+// Copyright (c) 2023 Don Owens <don@regexguy.com>.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// * Redistributions of source code must retain the above copyright notice,
+//   this list of conditions and the following disclaimer.
+//
+// * Redistributions in binary form must reproduce the above copyright notice,
+//   this list of conditions and the following disclaimer in the documentation
+//   and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+import (
+	// Built-in/core modules.
+
+	// Third-party modules.
+
+	// Generated code.
+	// First-party modules.
+	docdata "github.com/cuberat/protoc-gen-docjson/internal/docdata"
+)
+
+const (
+	JSON_SCHEMA_DIALECT     = "https://json-schema.org/draft/2020-12/schema"
+	JSON_SCHEMA_REF_PREFIX  = "#/$defs/"
+	JSON_SCHEMA_EXT         = ".schema.json"
+	JSON_SCHEMA_BUNDLE_FILE = "schema.json"
+)
+
+// Writes a JSON Schema (draft 2020-12) document for each message, named after
+// the fully-qualified message name (e.g., `foo.v1.Bar.schema.json`). Each
+// document refers to the message's definition in its `$defs`, alongside the
+// definitions of the messages and enums it uses, so recursive messages work.
+//
+// With the `schema_bundle` plugin option, a single document is written to
+// the `outfile` instead, with the definitions of all messages and enums in
+// `$defs`.
+func gen_json_schema(
+	data *docdata.TemplateData,
+	conf *docdata.Config,
+) ([]*OutputFile, error) {
+	if conf.PluginOpts.SchemaBundle {
+		return gen_json_schema_bundle(data, conf)
+	}
+
+	out_files := make([]*OutputFile, 0, len(data.MessageList))
+	for _, msg_name := range data.MessageList {
+		msg := data.MessageMap[msg_name]
+		if msg.Options != nil && msg.Options.MapEntry {
+			continue
+		}
+
+		builder := new_schema_builder(data, JSON_SCHEMA_REF_PREFIX)
+		file_name := msg_name + JSON_SCHEMA_EXT
+		doc := schema{
+			"$schema": JSON_SCHEMA_DIALECT,
+			"$id":     file_name,
+			"$ref":    builder.ref(msg_name)["$ref"],
+		}
+		defs := make(map[string]any)
+		builder.add_definitions(defs)
+		doc["$defs"] = defs
+
+		content, err := marshal_document(doc, file_name, conf)
+		if err != nil {
+			return nil, err
+		}
+		out_files = append(out_files,
+			&OutputFile{Name: file_name, Content: content})
+	}
+
+	return out_files, nil
+}
+
+func gen_json_schema_bundle(
+	data *docdata.TemplateData,
+	conf *docdata.Config,
+) ([]*OutputFile, error) {
+	builder := new_schema_builder(data, JSON_SCHEMA_REF_PREFIX)
+	for _, msg_name := range data.MessageList {
+		msg := data.MessageMap[msg_name]
+		if msg.Options == nil || !msg.Options.MapEntry {
+			builder.ref(msg_name)
+		}
+	}
+	for _, enum_name := range data.EnumList {
+		builder.ref(enum_name)
+	}
+
+	defs := make(map[string]any)
+	builder.add_definitions(defs)
+
+	out_file := conf.PluginOpts.OutFile
+	if out_file == "" {
+		out_file = JSON_SCHEMA_BUNDLE_FILE
+	}
+	doc := schema{
+		"$schema": JSON_SCHEMA_DIALECT,
+		"$id":     out_file,
+		"$defs":   defs,
+	}
+
+	content, err := marshal_document(doc, out_file, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return []*OutputFile{{Name: out_file, Content: content}}, nil
+}
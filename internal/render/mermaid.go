@@ -0,0 +1,192 @@
+package render
+
+// This file contains the generator for Mermaid diagrams (`outfmt=mermaid`).
+// Graphs are written as flowcharts, and message diagrams as class diagrams.
+
+// BSD 2-Clause License
+//
+// Copyright (c) 2023 Don Owens <don@regexguy.com>.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// * Redistributions of source code must retain the above copyright notice,
+//   this list of conditions and the following disclaimer.
+//
+// * Redistributions in binary form must reproduce the above copyright notice,
+//   this list of conditions and the following disclaimer in the documentation
+//   and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+import (
+	// Built-in/core modules.
+	"fmt"
+	"regexp"
+	"strings"
+
+	// Third-party modules.
+
+	// Generated code.
+	// First-party modules.
+	docdata "github.com/cuberat/protoc-gen-docjson/internal/docdata"
+)
+
+const MERMAID_EXT = ".mmd"
+
+// Node shapes in flowcharts, by kind of node, as the opening and closing
+// delimiters of the label.
+var mermaid_shapes = map[string][2]string{
+	NODE_PACKAGE: {"[", "]"},
+	NODE_FILE:    {">", "]"},
+	NODE_SERVICE: {"[[", "]]"},
+	NODE_METHOD:  {"([", "])"},
+	NODE_MESSAGE: {"[", "]"},
+	NODE_ENUM:    {"{{", "}}"},
+}
+
+// Matches characters that aren't allowed in Mermaid identifiers.
+var mermaid_invalid_id_re = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+func gen_mermaid(
+	data *docdata.TemplateData,
+	conf *docdata.Config,
+) ([]*OutputFile, error) {
+	diagrams := build_diagrams(data, conf)
+	out_files := make([]*OutputFile, 0, len(diagrams))
+	for _, d := range diagrams {
+		content := ""
+		if d.class {
+			content = render_mermaid_class_diagram(d)
+		} else {
+			content = render_mermaid_flowchart(d)
+		}
+
+		out_files = append(out_files, &OutputFile{
+			Name:    d.name + MERMAID_EXT,
+			Content: content,
+		})
+	}
+
+	return out_files, nil
+}
+
+func render_mermaid_flowchart(d *diagram) string {
+	var buf strings.Builder
+
+	fmt.Fprintf(&buf, "---\ntitle: %s\n---\n", mermaid_quote(d.title))
+	buf.WriteString("flowchart LR\n")
+
+	write_nodes := func(nodes []*diagram_node, prefix string) {
+		for _, node := range nodes {
+			shape := mermaid_shapes[node.kind]
+			fmt.Fprintf(&buf, "%s%s%s%s%s\n", prefix, node.id, shape[0],
+				mermaid_quote(node.label), shape[1])
+		}
+	}
+
+	if d.cluster {
+		pkgs, clusters := d.get_clusters()
+		for i, pkg := range pkgs {
+			if pkg == "" {
+				write_nodes(clusters[pkg], "    ")
+				continue
+			}
+
+			fmt.Fprintf(&buf, "    subgraph c%d[%s]\n", i, mermaid_quote(pkg))
+			write_nodes(clusters[pkg], "        ")
+			buf.WriteString("    end\n")
+		}
+	} else {
+		write_nodes(d.nodes, "    ")
+	}
+
+	for _, edge := range d.edges {
+		arrow := "-->"
+		if edge.dashed {
+			arrow = "-.->"
+		}
+		if edge.label != "" {
+			arrow += "|" + mermaid_quote(edge.label) + "|"
+		}
+
+		fmt.Fprintf(&buf, "    %s %s %s\n", edge.from.id, arrow, edge.to.id)
+	}
+
+	return buf.String()
+}
+
+func render_mermaid_class_diagram(d *diagram) string {
+	var buf strings.Builder
+
+	fmt.Fprintf(&buf, "---\ntitle: %s\n---\n", mermaid_quote(d.title))
+	buf.WriteString("classDiagram\n")
+
+	write_classes := func(nodes []*diagram_node, prefix string) {
+		for _, node := range nodes {
+			fmt.Fprintf(&buf, "%sclass %s[%s]", prefix, node.id,
+				mermaid_quote(node.label))
+			if len(node.members) == 0 {
+				buf.WriteString("\n")
+				continue
+			}
+
+			buf.WriteString(" {\n")
+			if node.kind == NODE_ENUM {
+				fmt.Fprintf(&buf, "%s    <<enumeration>>\n", prefix)
+			}
+			for _, member := range node.members {
+				fmt.Fprintf(&buf, "%s    %s\n", prefix,
+					mermaid_member(member))
+			}
+			fmt.Fprintf(&buf, "%s}\n", prefix)
+		}
+	}
+
+	if d.cluster {
+		pkgs, clusters := d.get_clusters()
+		for _, pkg := range pkgs {
+			if pkg == "" {
+				write_classes(clusters[pkg], "    ")
+				continue
+			}
+
+			fmt.Fprintf(&buf, "    namespace %s {\n",
+				mermaid_invalid_id_re.ReplaceAllString(pkg, "_"))
+			write_classes(clusters[pkg], "        ")
+			buf.WriteString("    }\n")
+		}
+	} else {
+		write_classes(d.nodes, "    ")
+	}
+
+	for _, edge := range d.edges {
+		fmt.Fprintf(&buf, "    %s --> %s : %s\n", edge.from.id, edge.to.id,
+			edge.label)
+	}
+
+	return buf.String()
+}
+
+// Returns a quoted label. Double quotes can't be escaped with a backslash,
+// so they're written as an entity.
+func mermaid_quote(text string) string {
+	return `"` + strings.ReplaceAll(text, `"`, "#quot;") + `"`
+}
+
+// Returns a class member with generic types written with tildes (e.g.,
+// `map~string, Item~`), since angle brackets aren't allowed.
+func mermaid_member(member string) string {
+	return strings.NewReplacer("<", "~", ">", "~", "{", "", "}",
+		"").Replace(member)
+}
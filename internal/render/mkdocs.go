@@ -0,0 +1,121 @@
+package render
+
+// This file contains the generator for an MkDocs documentation site
+// (`outfmt=mkdocs`).
+
+// BSD 2-Clause License
+//
+// Copyright (c) 2023 Don Owens <don@regexguy.com>.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// * Redistributions of source code must retain the above copyright notice,
+//   this list of conditions and the following disclaimer.
+//
+// * Redistributions in binary form must reproduce the above copyright notice,
+//   this list of conditions and the following disclaimer in the documentation
+//   and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+import (
+	// Built-in/core modules.
+	"fmt"
+	"strings"
+
+	// Third-party modules.
+	yaml "gopkg.in/yaml.v3"
+
+	// Generated code.
+	// First-party modules.
+	docdata "github.com/cuberat/protoc-gen-docjson/internal/docdata"
+)
+
+const (
+	MKDOCS_CONFIG_FILE = "mkdocs.yml"
+	MKDOCS_DOCS_DIR    = "docs"
+)
+
+type mkdocs_config struct {
+	SiteName string `yaml:"site_name"`
+	DocsDir  string `yaml:"docs_dir"`
+	Nav      []any  `yaml:"nav"`
+}
+
+// Writes an MkDocs site: `mkdocs.yml`, with the navigation, and a
+// documentation tree in the `docs` directory, so that `mkdocs build` can be
+// run in the output directory.
+func gen_mkdocs(
+	data *docdata.TemplateData,
+	conf *docdata.Config,
+) ([]*OutputFile, error) {
+	site := new_doc_tree(data, conf, MKDOCS_DOCS_DIR+"/", false)
+
+	tmpl_set, err := site.load_templates(conf, false, "markdown", "doctree",
+		"mkdocs")
+	if err != nil {
+		return nil, err
+	}
+
+	out_files, err := site.render_pages(tmpl_set)
+	if err != nil {
+		return nil, err
+	}
+
+	config_yaml, err := yaml.Marshal(get_mkdocs_config(site))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't marshal %s: %w", MKDOCS_CONFIG_FILE,
+			err)
+	}
+
+	return append(out_files, &OutputFile{
+		Name:    MKDOCS_CONFIG_FILE,
+		Content: string(config_yaml),
+	}), nil
+}
+
+// Returns the MkDocs configuration, with a navigation section for each
+// package, and sections for its services, messages, and enums.
+func get_mkdocs_config(site *doc_site) *mkdocs_config {
+	nav_path := func(page *doc_page) string {
+		return strings.TrimPrefix(page.FileName, MKDOCS_DOCS_DIR+"/")
+	}
+
+	home := site.pages[0]
+	nav := []any{map[string]any{"Home": nav_path(home)}}
+	for _, pkg_page := range site.package_pages() {
+		pkg_nav := []any{map[string]any{"Overview": nav_path(pkg_page)}}
+		for _, section := range doc_tree_sections {
+			children := pkg_page.children_of_kind(section.Kind)
+			if len(children) == 0 {
+				continue
+			}
+
+			section_nav := make([]any, 0, len(children))
+			for _, child := range children {
+				section_nav = append(section_nav,
+					map[string]any{child.Title: nav_path(child)})
+			}
+			pkg_nav = append(pkg_nav,
+				map[string]any{section.Title: section_nav})
+		}
+		nav = append(nav, map[string]any{pkg_page.Title: pkg_nav})
+	}
+
+	return &mkdocs_config{
+		SiteName: home.Title,
+		DocsDir:  MKDOCS_DOCS_DIR,
+		Nav:      nav,
+	}
+}
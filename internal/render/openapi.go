@@ -0,0 +1,518 @@
+package render
+
+// This file contains the generator for OpenAPI 3.1 documents describing the
+// REST interface of services transcoded with HTTP rules (`outfmt=openapi`).
+
+// BSD 2-Clause License
+//
+// Copyright (c) 2023 Don Owens <don@regexguy.com>.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// * Redistributions of source code must retain the above copyright notice,
+//   this list of conditions and the following disclaimer.
+//
+// * Redistributions in binary form must reproduce the above copyright notice,
+//   this list of conditions and the following disclaimer in the documentation
+//   and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+import (
+	// Built-in/core modules.
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	// Third-party modules.
+	log "github.com/sirupsen/logrus"
+
+	// Generated code.
+	// First-party modules.
+	docdata "github.com/cuberat/protoc-gen-docjson/internal/docdata"
+)
+
+const (
+	OPENAPI_VERSION      = "3.1.0"
+	OPENAPI_DEFAULT_FILE = "openapi.json"
+	OPENAPI_REF_PREFIX   = "#/components/schemas/"
+
+	// Used for `info.version` when the `api_version` plugin option isn't
+	// given, since it is required.
+	DEFAULT_API_VERSION = "unversioned"
+
+	// Name of the schema for error responses.
+	STATUS_SCHEMA = "google.rpc.Status"
+)
+
+// Matches a variable in an HTTP rule path template, e.g., `{name}` or
+// `{name=shelves/*}`.
+var path_var_re = regexp.MustCompile(`\{([^}=]+)(=[^}]*)?\}`)
+
+// A path parameter for a variable in an HTTP rule path template.
+type path_param struct {
+	// Name of the parameter in the OpenAPI path.
+	name string
+
+	// Path of the request field the variable is bound to, e.g., `book.name`.
+	field_path string
+
+	// If the variable has a pattern with literal segments, the pattern with
+	// the parameters filled in, e.g., `shelves/{shelf}/books/{book}`. The
+	// parameter is one segment of the field's value.
+	pattern string
+}
+
+// HTTP methods that OpenAPI supports.
+var openapi_methods = map[string]bool{
+	"get": true, "put": true, "post": true, "delete": true, "options": true,
+	"head": true, "patch": true, "trace": true,
+}
+
+type openapi_doc struct {
+	OpenAPI    string                                   `json:"openapi" yaml:"openapi"`
+	Info       *openapi_info                            `json:"info" yaml:"info"`
+	Tags       []*openapi_tag                           `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Paths      map[string]map[string]*openapi_operation `json:"paths" yaml:"paths"`
+	Components *openapi_components                      `json:"components" yaml:"components"`
+}
+
+type openapi_info struct {
+	Title       string `json:"title" yaml:"title"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	Version     string `json:"version" yaml:"version"`
+}
+
+type openapi_tag struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+type openapi_operation struct {
+	OperationID string                       `json:"operationId" yaml:"operationId"`
+	Summary     string                       `json:"summary,omitempty" yaml:"summary,omitempty"`
+	Description string                       `json:"description,omitempty" yaml:"description,omitempty"`
+	Tags        []string                     `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Deprecated  bool                         `json:"deprecated,omitempty" yaml:"deprecated,omitempty"`
+	Parameters  []*openapi_parameter         `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	RequestBody *openapi_request_body        `json:"requestBody,omitempty" yaml:"requestBody,omitempty"`
+	Responses   map[string]*openapi_response `json:"responses" yaml:"responses"`
+}
+
+type openapi_parameter struct {
+	Name        string `json:"name" yaml:"name"`
+	In          string `json:"in" yaml:"in"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	Required    bool   `json:"required,omitempty" yaml:"required,omitempty"`
+	Deprecated  bool   `json:"deprecated,omitempty" yaml:"deprecated,omitempty"`
+	Schema      schema `json:"schema" yaml:"schema"`
+}
+
+type openapi_request_body struct {
+	Description string                         `json:"description,omitempty" yaml:"description,omitempty"`
+	Required    bool                           `json:"required" yaml:"required"`
+	Content     map[string]*openapi_media_type `json:"content" yaml:"content"`
+}
+
+type openapi_response struct {
+	Description string                         `json:"description" yaml:"description"`
+	Content     map[string]*openapi_media_type `json:"content,omitempty" yaml:"content,omitempty"`
+}
+
+type openapi_media_type struct {
+	Schema schema `json:"schema" yaml:"schema"`
+}
+
+type openapi_components struct {
+	Schemas map[string]any `json:"schemas" yaml:"schemas"`
+}
+
+// Writes an OpenAPI document for the methods with HTTP rules
+// (`google.api.http` options). Schemas are built from the messages and enums
+// using the protobuf JSON mapping.
+func gen_openapi(
+	data *docdata.TemplateData,
+	conf *docdata.Config,
+) ([]*OutputFile, error) {
+	builder := new_schema_builder(data, OPENAPI_REF_PREFIX)
+	doc := &openapi_doc{
+		OpenAPI:    OPENAPI_VERSION,
+		Info:       get_openapi_info(data, conf),
+		Tags:       make([]*openapi_tag, 0),
+		Paths:      make(map[string]map[string]*openapi_operation),
+		Components: &openapi_components{Schemas: make(map[string]any)},
+	}
+
+	for _, svc_name := range data.ServiceList {
+		svc := data.ServiceMap[svc_name]
+		has_operations := false
+
+		for _, method := range svc.Methods {
+			rules, err := get_http_rules(method)
+			if err != nil {
+				return nil, fmt.Errorf("couldn't get HTTP rules for %s: %w",
+					method.FullName, err)
+			}
+
+			for i, rule := range rules {
+				if !openapi_methods[rule.Method] {
+					log.Warnf("skipping HTTP rule for %s with unsupported "+
+						"method %q", method.FullName, rule.Method)
+					continue
+				}
+
+				op_path, params := get_openapi_path(rule.Path)
+				op := get_openapi_operation(data, builder, svc, method, rule,
+					params)
+				if i > 0 {
+					op.OperationID += "_" + strconv.Itoa(i+1)
+				}
+
+				if doc.Paths[op_path] == nil {
+					doc.Paths[op_path] = make(map[string]*openapi_operation)
+				}
+				if existing := doc.Paths[op_path][rule.Method]; existing != nil {
+					return nil, fmt.Errorf("%s %s is bound to both %s and %s",
+						strings.ToUpper(rule.Method), op_path,
+						existing.OperationID, op.OperationID)
+				}
+				doc.Paths[op_path][rule.Method] = op
+				has_operations = true
+			}
+		}
+
+		if has_operations {
+			doc.Tags = append(doc.Tags, &openapi_tag{
+				Name:        svc.Name,
+				Description: svc.Description,
+			})
+		}
+	}
+
+	builder.add_definitions(doc.Components.Schemas)
+	if _, ok := doc.Components.Schemas[STATUS_SCHEMA]; !ok {
+		doc.Components.Schemas[STATUS_SCHEMA] = get_status_schema()
+	}
+
+	out_file := conf.PluginOpts.OutFile
+	if out_file == "" {
+		out_file = OPENAPI_DEFAULT_FILE
+	}
+	content, err := marshal_document(doc, out_file, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return []*OutputFile{{Name: out_file, Content: content}}, nil
+}
+
+// Returns the `info` object, from the `api_title` and `api_version` plugin
+// options. If there is only one package, the title and description default
+// to the package name and description.
+func get_openapi_info(
+	data *docdata.TemplateData,
+	conf *docdata.Config,
+) *openapi_info {
+	info := &openapi_info{
+		Title:   conf.PluginOpts.APITitle,
+		Version: conf.PluginOpts.APIVersion,
+	}
+
+	if len(data.PackageList) == 1 {
+		pkg := data.PackageMap[data.PackageList[0]]
+		if info.Title == "" {
+			info.Title = pkg.Name
+		}
+		info.Description = pkg.Description
+	}
+	if info.Title == "" {
+		info.Title = "API"
+	}
+	if info.Version == "" {
+		info.Version = DEFAULT_API_VERSION
+	}
+
+	return info
+}
+
+// Returns the OpenAPI path for an HTTP rule path template, and its path
+// parameters. Variables with a pattern of literal segments are expanded, with
+// a parameter for each wildcard named after the singular of the preceding
+// segment, e.g., `/v1/{name=shelves/*}` becomes `/v1/shelves/{shelf}`, so that
+// templates for different resources stay distinct. Other variables become a
+// single parameter named after the field, e.g., `/v1/books/{name}`.
+func get_openapi_path(template string) (string, []*path_param) {
+	params := make([]*path_param, 0)
+	used := make(map[string]bool)
+	unique_name := func(name, field_path string) string {
+		if used[name] {
+			name = strings.ReplaceAll(field_path, ".", "_") + "_" + name
+		}
+		base := name
+		for i := 2; used[name]; i++ {
+			name = base + strconv.Itoa(i)
+		}
+		used[name] = true
+		return name
+	}
+
+	op_path := path_var_re.ReplaceAllStringFunc(template, func(v string) string {
+		match := path_var_re.FindStringSubmatch(v)
+		field_path := match[1]
+		pattern := strings.TrimPrefix(match[2], "=")
+		if pattern == "" || !strings.Contains(pattern, "/") {
+			name := unique_name(field_path, field_path)
+			params = append(params, &path_param{name: name,
+				field_path: field_path})
+			return "{" + name + "}"
+		}
+
+		segments := strings.Split(pattern, "/")
+		var_params := make([]*path_param, 0)
+		for i, segment := range segments {
+			if segment != "*" && segment != "**" {
+				continue
+			}
+			name := field_path
+			if i > 0 && !strings.HasPrefix(segments[i-1], "*") {
+				name = singular_name(segments[i-1])
+			}
+			param := &path_param{name: unique_name(name, field_path),
+				field_path: field_path}
+			segments[i] = "{" + param.name + "}"
+			var_params = append(var_params, param)
+		}
+
+		expanded := strings.Join(segments, "/")
+		for _, param := range var_params {
+			param.pattern = expanded
+		}
+		params = append(params, var_params...)
+
+		return expanded
+	})
+
+	return op_path, params
+}
+
+// Returns the singular of a (plural) collection name in a resource name,
+// e.g., `shelf` for `shelves`, or `book` for `books`. This is a heuristic,
+// since the names are only used to name path parameters.
+func singular_name(collection string) string {
+	switch {
+	case strings.HasSuffix(collection, "lves"):
+		return strings.TrimSuffix(collection, "ves") + "f"
+	case strings.HasSuffix(collection, "ies"):
+		return strings.TrimSuffix(collection, "ies") + "y"
+	case strings.HasSuffix(collection, "sses"),
+		strings.HasSuffix(collection, "xes"),
+		strings.HasSuffix(collection, "ches"),
+		strings.HasSuffix(collection, "shes"):
+		return strings.TrimSuffix(collection, "es")
+	case strings.HasSuffix(collection, "ss"):
+		return collection
+	}
+
+	return strings.TrimSuffix(collection, "s")
+}
+
+func get_openapi_operation(
+	data *docdata.TemplateData,
+	builder *schema_builder,
+	svc *docdata.ServiceData,
+	method *docdata.MethodData,
+	rule *http_rule,
+	path_params []*path_param,
+) *openapi_operation {
+	op := &openapi_operation{
+		OperationID: svc.Name + "_" + method.Name,
+		Summary:     method.Summary,
+		Description: method.Description,
+		Tags:        []string{svc.Name},
+		Deprecated:  is_deprecated(method),
+		Parameters:  make([]*openapi_parameter, 0),
+		Responses:   make(map[string]*openapi_response),
+	}
+	if op.Description == op.Summary {
+		op.Description = ""
+	}
+
+	request := data.MessageMap[strings.TrimPrefix(method.RequestFullType, ".")]
+
+	// Fields bound to the path or the body aren't query parameters.
+	bound_fields := make(map[string]bool)
+	for _, var_param := range path_params {
+		bound_fields[strings.Split(var_param.field_path, ".")[0]] = true
+
+		param := &openapi_parameter{
+			Name:     var_param.name,
+			In:       "path",
+			Required: true,
+			Schema:   schema{"type": "string"},
+		}
+		field := find_field_by_path(data, request, var_param.field_path)
+		if var_param.pattern != "" {
+			param.Description = fmt.Sprintf("Segment of `%s`, which has "+
+				"the form `%s`.", var_param.field_path, var_param.pattern)
+			if field != nil {
+				param.Deprecated = is_deprecated(field)
+			}
+		} else if field != nil {
+			param.Description = field.Description
+			param.Deprecated = is_deprecated(field)
+			param.Schema = builder.value_schema(field)
+		}
+		op.Parameters = append(op.Parameters, param)
+	}
+
+	switch rule.Body {
+	case "":
+	case "*":
+		op.RequestBody = json_request_body(
+			builder.type_schema("message", method.RequestFullType))
+	default:
+		bound_fields[rule.Body] = true
+		if field := find_field_by_path(data, request, rule.Body); field != nil {
+			op.RequestBody = json_request_body(builder.field_schema(field))
+			op.RequestBody.Description = field.Description
+		}
+	}
+
+	if request != nil && rule.Body != "*" {
+		for _, field := range request.Fields {
+			if bound_fields[field.Name] || !is_query_param_field(field) {
+				continue
+			}
+			op.Parameters = append(op.Parameters, &openapi_parameter{
+				Name:        field.JSONName,
+				In:          "query",
+				Description: field.Description,
+				Deprecated:  is_deprecated(field),
+				Schema:      builder.field_schema(field),
+			})
+		}
+	}
+
+	var response_schema schema
+	if rule.ResponseBody != "" {
+		response := data.MessageMap[strings.TrimPrefix(method.ResponseFullType,
+			".")]
+		if field := find_field_by_path(data, response, rule.ResponseBody); field != nil {
+			response_schema = builder.field_schema(field)
+		}
+	}
+	if response_schema == nil {
+		response_schema = builder.type_schema("message",
+			method.ResponseFullType)
+	}
+	op.Responses["200"] = &openapi_response{
+		Description: "A successful response.",
+		Content: map[string]*openapi_media_type{
+			"application/json": {Schema: response_schema},
+		},
+	}
+	op.Responses["default"] = &openapi_response{
+		Description: "An error response.",
+		Content: map[string]*openapi_media_type{
+			"application/json": {
+				Schema: schema{"$ref": OPENAPI_REF_PREFIX + STATUS_SCHEMA},
+			},
+		},
+	}
+
+	return op
+}
+
+func json_request_body(body_schema schema) *openapi_request_body {
+	return &openapi_request_body{
+		Required: true,
+		Content: map[string]*openapi_media_type{
+			"application/json": {Schema: body_schema},
+		},
+	}
+}
+
+// Returns true if the field can be given as a query parameter: scalars,
+// enums, well-known types encoded as JSON scalars, and lists of those.
+func is_query_param_field(field *docdata.FieldData) bool {
+	if field.Kind != "message" && field.Kind != "group" {
+		return true
+	}
+
+	full_type := strings.TrimPrefix(field.FullTypeName, ".")
+	well_known, ok := well_known_schemas[full_type]
+	if !ok {
+		return false
+	}
+
+	type_name := well_known()["type"]
+	switch type_name := type_name.(type) {
+	case string:
+		return type_name != "object" && type_name != "array"
+	case []string:
+		return type_name[0] != "object" && type_name[0] != "array"
+	}
+
+	return false
+}
+
+// Returns the field at a dotted path of field names (e.g., `book.name`) in a
+// message, or nil if there isn't one.
+func find_field_by_path(
+	data *docdata.TemplateData,
+	msg *docdata.MessageData,
+	field_path string,
+) *docdata.FieldData {
+	var field *docdata.FieldData
+	for _, name := range strings.Split(field_path, ".") {
+		if msg == nil {
+			return nil
+		}
+
+		field = nil
+		for _, candidate := range msg.Fields {
+			if candidate.Name == name {
+				field = candidate
+				break
+			}
+		}
+		if field == nil {
+			return nil
+		}
+
+		msg = data.MessageMap[strings.TrimPrefix(field.FullTypeName, ".")]
+	}
+
+	return field
+}
+
+// Returns the schema for `google.rpc.Status`, the JSON body of error
+// responses from gRPC-transcoding gateways.
+func get_status_schema() schema {
+	return schema{
+		"type":  "object",
+		"title": "Status",
+		"description": "The error model used by gRPC, returned in the body " +
+			"of error responses.",
+		"properties": schema{
+			"code":    scalar_schema("int32"),
+			"message": scalar_schema("string"),
+			"details": schema{
+				"type":  "array",
+				"items": well_known_schemas["google.protobuf.Any"](),
+			},
+		},
+	}
+}
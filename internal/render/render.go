@@ -0,0 +1,223 @@
+package render
+
+// This file contains the code to render the template data with Go templates
+// (`text/template` or `html/template`) inside the plugin, so that the
+// documentation can be generated without a separate script.
+
+// BSD 2-Clause License
+//
+// Copyright (c) 2023 Don Owens <don@regexguy.com>.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// * Redistributions of source code must retain the above copyright notice,
+//   this list of conditions and the following disclaimer.
+//
+// * Redistributions in binary form must reproduce the above copyright notice,
+//   this list of conditions and the following disclaimer in the documentation
+//   and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+import (
+	// Built-in/core modules.
+	"bytes"
+	"encoding/json"
+	"fmt"
+	html_template "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	text_template "text/template"
+
+	// Third-party modules.
+	log "github.com/sirupsen/logrus"
+	yaml "gopkg.in/yaml.v3"
+
+	// Generated code.
+	// First-party modules.
+	docdata "github.com/cuberat/protoc-gen-docjson/internal/docdata"
+)
+
+// A rendered file, to be returned to the protobuf compiler.
+type OutputFile struct {
+	Name    string
+	Content string
+}
+
+// Anything that can be executed with the template data, i.e., a
+// `text/template` or `html/template` template.
+type executor interface {
+	Execute(writer io.Writer, data any) error
+}
+
+// Generates the output files for an output format.
+type generator func(
+	data *docdata.TemplateData,
+	conf *docdata.Config,
+) ([]*OutputFile, error)
+
+// Generators for the output formats that are produced by this package,
+// rather than by serializing the template data, keyed by the `outfmt`
+// plugin option.
+var generators = map[string]generator{
+	"markdown":   gen_markdown,
+	"html":       gen_html,
+	"asciidoc":   gen_asciidoc,
+	"rst":        gen_rst,
+	"mkdocs":     gen_mkdocs,
+	"docusaurus": gen_docusaurus,
+	"openapi":    gen_openapi,
+	"jsonschema": gen_json_schema,
+	"dot":        gen_dot,
+	"mermaid":    gen_mermaid,
+	"typescript": gen_typescript,
+	"csv":        gen_csv,
+	"tsv":        gen_tsv,
+}
+
+// Returns true if the output format is produced by `Generate()`.
+func HasGenerator(out_format string) bool {
+	_, ok := generators[out_format]
+	return ok
+}
+
+// Generates the output files for the format given by the `outfmt` plugin
+// option.
+func Generate(
+	data *docdata.TemplateData,
+	conf *docdata.Config,
+) ([]*OutputFile, error) {
+	out_format := conf.PluginOpts.OutFormat
+	gen, ok := generators[out_format]
+	if !ok {
+		return nil, fmt.Errorf("unknown output format %q", out_format)
+	}
+
+	return gen(data, conf)
+}
+
+// Renders the templates given by the `template` plugin option.
+func RenderTemplates(
+	data *docdata.TemplateData,
+	conf *docdata.Config,
+) ([]*OutputFile, error) {
+	out_files := make([]*OutputFile, 0, len(conf.PluginOpts.Templates))
+	for _, spec := range conf.PluginOpts.Templates {
+		out_file, err := render_template_file(data, spec)
+		if err != nil {
+			return nil, err
+		}
+		out_files = append(out_files, out_file)
+	}
+
+	return out_files, nil
+}
+
+func render_template_file(
+	data *docdata.TemplateData,
+	spec *docdata.TemplateSpec,
+) (*OutputFile, error) {
+	content, err := os.ReadFile(spec.Path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read template: %w", err)
+	}
+
+	out_name := spec.OutFile
+	if out_name == "" {
+		out_name = strings.TrimSuffix(filepath.Base(spec.Path), ".tmpl")
+	}
+
+	log.Debugf("rendering template %s to %s", spec.Path, out_name)
+
+	tmpl, err := parse_template(filepath.Base(spec.Path), string(content),
+		is_html_file(out_name), new_func_map(data))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse template %s: %w", spec.Path,
+			err)
+	}
+
+	rendered, err := execute_template(tmpl, data)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't render template %s: %w", spec.Path,
+			err)
+	}
+
+	return &OutputFile{Name: out_name, Content: rendered}, nil
+}
+
+// Parses a template, using `html/template` (which escapes values for HTML)
+// if `is_html` is true, and `text/template` otherwise.
+func parse_template(
+	name, content string,
+	is_html bool,
+	funcs map[string]any,
+) (executor, error) {
+	if is_html {
+		return html_template.New(name).Funcs(funcs).Parse(content)
+	}
+
+	return text_template.New(name).Funcs(funcs).Parse(content)
+}
+
+func execute_template(tmpl executor, data any) (string, error) {
+	buffer := new(bytes.Buffer)
+	if err := tmpl.Execute(buffer, data); err != nil {
+		return "", err
+	}
+
+	return buffer.String(), nil
+}
+
+// Returns true if output files with this name should be rendered with
+// `html/template`.
+func is_html_file(file_name string) bool {
+	ext := strings.ToLower(filepath.Ext(file_name))
+	return ext == ".html" || ext == ".htm"
+}
+
+// Marshals a document to JSON, or to YAML if the file name ends in `.yaml`
+// or `.yml`. JSON is indented if the `pretty` plugin option is given.
+func marshal_document(
+	doc any,
+	file_name string,
+	conf *docdata.Config,
+) (string, error) {
+	ext := strings.ToLower(filepath.Ext(file_name))
+	if ext == ".yaml" || ext == ".yml" {
+		yaml_bytes, err := yaml.Marshal(doc)
+		if err != nil {
+			return "", fmt.Errorf("couldn't marshal %s to YAML: %w", file_name,
+				err)
+		}
+		return string(yaml_bytes), nil
+	}
+
+	var (
+		json_bytes []byte
+		err        error
+	)
+	if conf.PluginOpts.PrettyPrint {
+		json_bytes, err = json.MarshalIndent(doc, "", "  ")
+	} else {
+		json_bytes, err = json.Marshal(doc)
+	}
+	if err != nil {
+		return "", fmt.Errorf("couldn't marshal %s to JSON: %w", file_name,
+			err)
+	}
+
+	return string(json_bytes) + "\n", nil
+}
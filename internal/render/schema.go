@@ -0,0 +1,401 @@
+package render
+
+// This file contains the code to build JSON Schemas (draft 2020-12) for the
+// protobuf JSON mapping of messages and enums, as used by the OpenAPI and
+// JSON Schema output formats.
+
+// BSD 2-Clause License
+//
+// Copyright (c) 2023 Don Owens <don@regexguy.com>.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// * Redistributions of source code must retain the above copyright notice,
+//   this list of conditions and the following disclaimer.
+//
+// * Redistributions in binary form must reproduce the above copyright notice,
+//   this list of conditions and the following disclaimer in the documentation
+//   and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+import (
+	// Built-in/core modules.
+	"math"
+	"strings"
+
+	// Third-party modules.
+	log "github.com/sirupsen/logrus"
+
+	// Generated code.
+	// First-party modules.
+	docdata "github.com/cuberat/protoc-gen-docjson/internal/docdata"
+)
+
+// A JSON Schema, as a JSON object.
+type schema = map[string]any
+
+// Schemas for the JSON representation of the well-known types, keyed by
+// fully-qualified type name.
+var well_known_schemas = map[string]func() schema{
+	"google.protobuf.Timestamp": func() schema {
+		return schema{"type": "string", "format": "date-time"}
+	},
+	"google.protobuf.Duration": func() schema {
+		return schema{"type": "string",
+			"pattern": `^-?[0-9]+(\.[0-9]{1,9})?s$`}
+	},
+	"google.protobuf.FieldMask": func() schema {
+		return schema{"type": "string"}
+	},
+	"google.protobuf.Struct": func() schema {
+		return schema{"type": "object"}
+	},
+	"google.protobuf.Value":     func() schema { return schema{} },
+	"google.protobuf.ListValue": func() schema { return schema{"type": "array"} },
+	"google.protobuf.NullValue": func() schema { return schema{"type": "null"} },
+	"google.protobuf.Any": func() schema {
+		return schema{
+			"type": "object",
+			"properties": schema{
+				"@type": schema{"type": "string"},
+			},
+			"required": []string{"@type"},
+		}
+	},
+	"google.protobuf.Empty": func() schema {
+		return schema{"type": "object", "additionalProperties": false}
+	},
+	"google.protobuf.BoolValue":   func() schema { return nullable(scalar_schema("bool")) },
+	"google.protobuf.StringValue": func() schema { return nullable(scalar_schema("string")) },
+	"google.protobuf.BytesValue":  func() schema { return nullable(scalar_schema("bytes")) },
+	"google.protobuf.Int32Value":  func() schema { return nullable(scalar_schema("int32")) },
+	"google.protobuf.UInt32Value": func() schema { return nullable(scalar_schema("uint32")) },
+	"google.protobuf.Int64Value":  func() schema { return nullable(scalar_schema("int64")) },
+	"google.protobuf.UInt64Value": func() schema { return nullable(scalar_schema("uint64")) },
+	"google.protobuf.FloatValue":  func() schema { return nullable(scalar_schema("float")) },
+	"google.protobuf.DoubleValue": func() schema { return nullable(scalar_schema("double")) },
+}
+
+// Builds schemas for messages and enums, referring to other messages and
+// enums by `$ref`, so that recursive messages can be represented. The names
+// of the referenced messages and enums are recorded, so that their
+// definitions can be added to the document. Types that aren't documented
+// (e.g., ones from imported files that aren't generated) have no
+// definition, so they are represented by an empty schema instead.
+type schema_builder struct {
+	data *docdata.TemplateData
+
+	// Prefix for `$ref` values, e.g., `#/$defs/`.
+	ref_prefix string
+
+	// Fully-qualified names of the referenced messages and enums, in the
+	// order they were first referenced.
+	refs     []string
+	ref_seen map[string]bool
+
+	// Fully-qualified names of the referenced types that have no
+	// definition, so that each is only warned about once.
+	undefined map[string]bool
+}
+
+func new_schema_builder(
+	data *docdata.TemplateData,
+	ref_prefix string,
+) *schema_builder {
+	return &schema_builder{
+		data:       data,
+		ref_prefix: ref_prefix,
+		refs:       make([]string, 0),
+		ref_seen:   make(map[string]bool),
+		undefined:  make(map[string]bool),
+	}
+}
+
+// Returns a `$ref` schema for a message or enum, recording the reference. If
+// the type has no definition, an empty schema (allowing any value) is
+// returned instead, so that the document doesn't contain a `$ref` that can't
+// be resolved.
+func (builder *schema_builder) ref(full_name string) schema {
+	full_name = strings.TrimPrefix(full_name, ".")
+	if builder.data.MessageMap[full_name] == nil &&
+		builder.data.EnumMap[full_name] == nil {
+		if !builder.undefined[full_name] {
+			builder.undefined[full_name] = true
+			log.Warnf("no definition for type %s (is its file generated?); "+
+				"using an empty schema", full_name)
+		}
+
+		return schema{}
+	}
+
+	if !builder.ref_seen[full_name] {
+		builder.ref_seen[full_name] = true
+		builder.refs = append(builder.refs, full_name)
+	}
+
+	return schema{"$ref": builder.ref_prefix + full_name}
+}
+
+// Adds the schemas for all referenced messages and enums (including the ones
+// they refer to) to `defs`, keyed by fully-qualified name.
+func (builder *schema_builder) add_definitions(defs map[string]any) {
+	for i := 0; i < len(builder.refs); i++ {
+		name := builder.refs[i]
+		if _, ok := defs[name]; ok {
+			continue
+		}
+		if def := builder.definition(name); def != nil {
+			defs[name] = def
+		}
+	}
+}
+
+// Returns the schema for the message or enum with the given name, or nil if
+// it isn't known.
+func (builder *schema_builder) definition(full_name string) schema {
+	if msg := builder.data.MessageMap[full_name]; msg != nil {
+		return builder.message_schema(msg)
+	}
+	if enum := builder.data.EnumMap[full_name]; enum != nil {
+		return builder.enum_schema(enum)
+	}
+
+	return nil
+}
+
+// Returns the schema for the JSON encoding of a message. Properties are keyed
+// by the JSON name of each field. Oneofs are represented with `oneOf`, so that
+// at most one of the fields in each oneof can be set.
+func (builder *schema_builder) message_schema(
+	msg *docdata.MessageData,
+) schema {
+	if well_known, ok := well_known_schemas[msg.FullName]; ok {
+		return describe(well_known(), msg.Name, &msg.CommentData,
+			is_deprecated(msg))
+	}
+
+	properties := make(map[string]any, len(msg.Fields))
+	oneofs := make(map[string][]string)
+	oneof_names := make([]string, 0)
+	for _, field := range msg.Fields {
+		properties[field.JSONName] = builder.field_schema(field)
+
+		if field.InOneof && !is_synthetic_oneof(msg, field) {
+			if _, ok := oneofs[field.OneofName]; !ok {
+				oneof_names = append(oneof_names, field.OneofName)
+			}
+			oneofs[field.OneofName] =
+				append(oneofs[field.OneofName], field.JSONName)
+		}
+	}
+
+	msg_schema := schema{
+		"type":       "object",
+		"properties": properties,
+	}
+
+	oneof_schemas := make([]any, 0, len(oneof_names))
+	for _, oneof_name := range oneof_names {
+		oneof_schemas = append(oneof_schemas, oneof_schema(oneofs[oneof_name]))
+	}
+	switch len(oneof_schemas) {
+	case 0:
+	case 1:
+		msg_schema["oneOf"] = oneof_schemas[0].(schema)["oneOf"]
+	default:
+		msg_schema["allOf"] = oneof_schemas
+	}
+
+	return describe(msg_schema, msg.Name, &msg.CommentData,
+		is_deprecated(msg))
+}
+
+// Returns a schema allowing at most one of the given properties.
+func oneof_schema(json_names []string) schema {
+	choices := make([]any, 0, len(json_names)+1)
+	any_of := make([]any, 0, len(json_names))
+	for _, json_name := range json_names {
+		required := schema{"required": []string{json_name}}
+		choices = append(choices, required)
+		any_of = append(any_of, required)
+	}
+	choices = append(choices, schema{"not": schema{"anyOf": any_of}})
+
+	return schema{"oneOf": choices}
+}
+
+// Returns true if the field is in the synthetic oneof the protobuf compiler
+// creates for a proto3 `optional` field.
+func is_synthetic_oneof(
+	msg *docdata.MessageData,
+	field *docdata.FieldData,
+) bool {
+	if field.OneofName != "_"+field.Name {
+		return false
+	}
+
+	for _, other := range msg.Fields {
+		if other != field && other.OneofName == field.OneofName {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns the schema for the JSON encoding of an enum: the names of the
+// values as strings.
+func (builder *schema_builder) enum_schema(enum *docdata.EnumData) schema {
+	if well_known, ok := well_known_schemas[enum.FullName]; ok {
+		return describe(well_known(), enum.Name, &enum.CommentData,
+			is_deprecated(enum))
+	}
+
+	names := make([]string, 0, len(enum.Values))
+	for _, value := range enum.Values {
+		names = append(names, value.Name)
+	}
+
+	return describe(schema{"type": "string", "enum": names}, enum.Name,
+		&enum.CommentData, is_deprecated(enum))
+}
+
+// Returns the schema for a field, including its description.
+func (builder *schema_builder) field_schema(field *docdata.FieldData) schema {
+	var field_schema schema
+
+	if entry := builder.data.GetMapEntry(field); entry != nil {
+		field_schema = schema{
+			"type":                 "object",
+			"additionalProperties": builder.value_schema(entry.Fields[1]),
+		}
+		if key_schema := map_key_schema(entry.Fields[0]); key_schema != nil {
+			field_schema["propertyNames"] = key_schema
+		}
+	} else if field.Label == "repeated" {
+		field_schema = schema{
+			"type":  "array",
+			"items": builder.value_schema(field),
+		}
+	} else {
+		field_schema = builder.value_schema(field)
+	}
+
+	return describe(field_schema, "", &field.CommentData,
+		is_deprecated(field))
+}
+
+// Returns the schema for a single value of a field, ignoring whether it is
+// repeated.
+func (builder *schema_builder) value_schema(field *docdata.FieldData) schema {
+	return builder.type_schema(field.Kind, field.FullTypeName)
+}
+
+// Returns the schema for a value of the given kind (e.g., "int64" or
+// "message") and type.
+func (builder *schema_builder) type_schema(kind, full_type string) schema {
+	full_type = strings.TrimPrefix(full_type, ".")
+
+	if well_known, ok := well_known_schemas[full_type]; ok {
+		return well_known()
+	}
+
+	switch kind {
+	case "message", "group", "enum":
+		return builder.ref(full_type)
+	}
+
+	return scalar_schema(kind)
+}
+
+// Returns the schema for a scalar type. 64-bit integers are encoded as
+// strings, and floating point numbers can also be "NaN", "Infinity", or
+// "-Infinity".
+func scalar_schema(kind string) schema {
+	switch kind {
+	case "double", "float":
+		return schema{"oneOf": []any{
+			schema{"type": "number"},
+			schema{"type": "string",
+				"enum": []string{"NaN", "Infinity", "-Infinity"}},
+		}}
+	case "int64", "sint64", "sfixed64":
+		return schema{"type": "string", "format": "int64",
+			"pattern": `^-?[0-9]+$`}
+	case "uint64", "fixed64":
+		return schema{"type": "string", "format": "uint64",
+			"pattern": `^[0-9]+$`}
+	case "int32", "sint32", "sfixed32":
+		return schema{"type": "integer", "format": "int32",
+			"minimum": math.MinInt32, "maximum": math.MaxInt32}
+	case "uint32", "fixed32":
+		return schema{"type": "integer", "format": "uint32",
+			"minimum": 0, "maximum": int64(math.MaxUint32)}
+	case "bool":
+		return schema{"type": "boolean"}
+	case "string":
+		return schema{"type": "string"}
+	case "bytes":
+		return schema{"type": "string", "contentEncoding": "base64"}
+	}
+
+	return schema{}
+}
+
+// Returns a `propertyNames` schema for the keys of a map, which are always
+// strings in JSON, or nil if any string is allowed.
+func map_key_schema(key_field *docdata.FieldData) schema {
+	switch key_field.Kind {
+	case "bool":
+		return schema{"enum": []string{"true", "false"}}
+	case "string":
+		return nil
+	case "uint32", "fixed32", "uint64", "fixed64":
+		return schema{"pattern": `^[0-9]+$`}
+	}
+
+	return schema{"pattern": `^-?[0-9]+$`}
+}
+
+// Makes a schema also accept null.
+func nullable(value_schema schema) schema {
+	if type_name, ok := value_schema["type"].(string); ok {
+		value_schema["type"] = []string{type_name, "null"}
+		return value_schema
+	}
+
+	return schema{"oneOf": []any{value_schema, schema{"type": "null"}}}
+}
+
+// Adds the title, description, and deprecation flag to a schema. These can
+// be given alongside `$ref` in draft 2020-12 (and OpenAPI 3.1).
+func describe(
+	in_schema schema,
+	title string,
+	comments *docdata.CommentData,
+	deprecated bool,
+) schema {
+	if title != "" {
+		in_schema["title"] = title
+	}
+	if comments.Description != "" {
+		in_schema["description"] = comments.Description
+	}
+	if deprecated {
+		in_schema["deprecated"] = true
+	}
+
+	return in_schema
+}
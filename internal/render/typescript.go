@@ -0,0 +1,424 @@
+package render
+
+// This file contains the generator for TypeScript declarations
+// (`outfmt=typescript`) describing the protobuf JSON encoding of messages and
+// enums.
+
+// BSD 2-Clause License
+//
+// Copyright (c) 2023 Don Owens <don@regexguy.com>.  All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// * Redistributions of source code must retain the above copyright notice,
+//   this list of conditions and the following disclaimer.
+//
+// * Redistributions in binary form must reproduce the above copyright notice,
+//   this list of conditions and the following disclaimer in the documentation
+//   and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+import (
+	// Built-in/core modules.
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	// Third-party modules.
+	// Generated code.
+	// First-party modules.
+	docdata "github.com/cuberat/protoc-gen-docjson/internal/docdata"
+)
+
+const TYPESCRIPT_DEFAULT_FILE = "types.d.ts"
+
+const TYPESCRIPT_INDENT = "  "
+
+// TypeScript types for the JSON representation of the well-known types,
+// keyed by fully-qualified type name.
+var well_known_ts_types = map[string]string{
+	"google.protobuf.Timestamp":   "string",
+	"google.protobuf.Duration":    "string",
+	"google.protobuf.FieldMask":   "string",
+	"google.protobuf.Struct":      "{ [key: string]: unknown }",
+	"google.protobuf.Value":       "unknown",
+	"google.protobuf.ListValue":   "unknown[]",
+	"google.protobuf.NullValue":   "null",
+	"google.protobuf.Any":         `{ "@type": string; [key: string]: unknown }`,
+	"google.protobuf.Empty":       "Record<string, never>",
+	"google.protobuf.BoolValue":   "boolean | null",
+	"google.protobuf.StringValue": "string | null",
+	"google.protobuf.BytesValue":  "string | null",
+	"google.protobuf.Int32Value":  "number | null",
+	"google.protobuf.UInt32Value": "number | null",
+	"google.protobuf.Int64Value":  "string | null",
+	"google.protobuf.UInt64Value": "string | null",
+	"google.protobuf.FloatValue":  `number | "NaN" | "Infinity" | "-Infinity" | null`,
+	"google.protobuf.DoubleValue": `number | "NaN" | "Infinity" | "-Infinity" | null`,
+}
+
+// Property names that can be used without quotes.
+var ts_identifier_re = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// Writes TypeScript declarations for messages and enums.
+type ts_writer struct {
+	data *docdata.TemplateData
+	buf  strings.Builder
+}
+
+func gen_typescript(
+	data *docdata.TemplateData,
+	conf *docdata.Config,
+) ([]*OutputFile, error) {
+	out_file := conf.PluginOpts.OutFile
+	if out_file == "" {
+		out_file = TYPESCRIPT_DEFAULT_FILE
+	}
+
+	writer := &ts_writer{data: data}
+	writer.buf.WriteString("// Type declarations for the protobuf JSON " +
+		"encoding of the messages and enums\n" +
+		"// in this API. These are for documentation only; they are not " +
+		"generated code\n// for serializing or validating messages.\n")
+
+	// Group the top-level messages and enums by package, keeping the order
+	// of the files.
+	packages := make([]string, 0)
+	files_by_package := make(map[string][]*docdata.FileData)
+	for _, file_name := range data.FileList {
+		file_data := data.FileMap[file_name]
+		if file_data == nil {
+			continue
+		}
+		if _, ok := files_by_package[file_data.Package]; !ok {
+			packages = append(packages, file_data.Package)
+		}
+		files_by_package[file_data.Package] =
+			append(files_by_package[file_data.Package], file_data)
+	}
+
+	for _, pkg := range packages {
+		indent := ""
+		writer.buf.WriteString("\n")
+		if pkg != "" {
+			fmt.Fprintf(&writer.buf, "export namespace %s {\n", pkg)
+			indent = TYPESCRIPT_INDENT
+		}
+
+		first := true
+		for _, file_data := range files_by_package[pkg] {
+			for _, msg := range file_data.Messages {
+				first = writer.write_message(msg, indent, first)
+			}
+			for _, enum := range file_data.Enums {
+				first = writer.write_enum(enum, indent, first)
+			}
+		}
+
+		if pkg != "" {
+			writer.buf.WriteString("}\n")
+		}
+	}
+
+	return []*OutputFile{{Name: out_file,
+		Content: writer.buf.String()}}, nil
+}
+
+// Writes the declaration for a message, followed by a namespace for its
+// nested messages and enums. Map entries are skipped, since maps are
+// declared inline. Returns whether nothing has been written in the current
+// block.
+func (writer *ts_writer) write_message(
+	msg *docdata.MessageData,
+	indent string,
+	first bool,
+) bool {
+	if msg.Options != nil && msg.Options.MapEntry {
+		return first
+	}
+	if !first {
+		writer.buf.WriteString("\n")
+	}
+
+	writer.write_doc(indent, &msg.CommentData, is_deprecated(msg))
+
+	// Fields in a (non-synthetic) oneof are declared separately, as a
+	// discriminated union per oneof.
+	fields := make([]*docdata.FieldData, 0, len(msg.Fields))
+	oneofs := make(map[string][]*docdata.FieldData)
+	oneof_names := make([]string, 0)
+	for _, field := range msg.Fields {
+		if !field.InOneof || is_synthetic_oneof(msg, field) {
+			fields = append(fields, field)
+			continue
+		}
+		if _, ok := oneofs[field.OneofName]; !ok {
+			oneof_names = append(oneof_names, field.OneofName)
+		}
+		oneofs[field.OneofName] = append(oneofs[field.OneofName], field)
+	}
+
+	inner := indent + TYPESCRIPT_INDENT
+	if len(oneof_names) == 0 {
+		fmt.Fprintf(&writer.buf, "%sexport interface %s {\n", indent,
+			msg.Name)
+		writer.write_fields(fields, inner)
+		fmt.Fprintf(&writer.buf, "%s}\n", indent)
+	} else {
+		fmt.Fprintf(&writer.buf, "%sexport type %s = {\n", indent, msg.Name)
+		writer.write_fields(fields, inner)
+		fmt.Fprintf(&writer.buf, "%s}", indent)
+		for _, oneof_name := range oneof_names {
+			writer.buf.WriteString(" & (\n")
+			for _, oneof := range msg.OneofDecls {
+				if oneof.Name == oneof_name {
+					writer.write_doc(inner, &oneof.CommentData, false)
+				}
+			}
+			writer.write_oneof(oneofs[oneof_name], inner)
+			fmt.Fprintf(&writer.buf, "%s)", indent)
+		}
+		writer.buf.WriteString(";\n")
+	}
+
+	nested := make([]*docdata.MessageData, 0, len(msg.NestedMessages))
+	for _, nested_msg := range msg.NestedMessages {
+		if nested_msg.Options == nil || !nested_msg.Options.MapEntry {
+			nested = append(nested, nested_msg)
+		}
+	}
+	if len(nested) == 0 && len(msg.Enums) == 0 {
+		return false
+	}
+
+	fmt.Fprintf(&writer.buf, "%sexport namespace %s {\n", indent, msg.Name)
+	nested_first := true
+	for _, nested_msg := range nested {
+		nested_first = writer.write_message(nested_msg, inner, nested_first)
+	}
+	for _, enum := range msg.Enums {
+		nested_first = writer.write_enum(enum, inner, nested_first)
+	}
+	fmt.Fprintf(&writer.buf, "%s}\n", indent)
+
+	return false
+}
+
+// Writes optional properties for the given fields. Fields are always
+// optional, since fields with default values are omitted from the JSON
+// encoding.
+func (writer *ts_writer) write_fields(
+	fields []*docdata.FieldData,
+	indent string,
+) {
+	for _, field := range fields {
+		writer.write_doc(indent, &field.CommentData, is_deprecated(field))
+		fmt.Fprintf(&writer.buf, "%s%s?: %s;\n", indent,
+			ts_property_name(field.JSONName), writer.field_type(field))
+	}
+}
+
+// Writes the union for a oneof: one member per field, where that field is
+// set and the others are not, plus a member where none of them are set.
+func (writer *ts_writer) write_oneof(
+	fields []*docdata.FieldData,
+	indent string,
+) {
+	member_indent := indent + TYPESCRIPT_INDENT + TYPESCRIPT_INDENT
+	for _, field := range fields {
+		fmt.Fprintf(&writer.buf, "%s| {\n", indent)
+		writer.write_doc(member_indent, &field.CommentData,
+			is_deprecated(field))
+		fmt.Fprintf(&writer.buf, "%s%s: %s;\n", member_indent,
+			ts_property_name(field.JSONName), writer.field_type(field))
+		for _, other := range fields {
+			if other != field {
+				fmt.Fprintf(&writer.buf, "%s%s?: never;\n", member_indent,
+					ts_property_name(other.JSONName))
+			}
+		}
+		fmt.Fprintf(&writer.buf, "%s  }\n", indent)
+	}
+
+	fmt.Fprintf(&writer.buf, "%s| {\n", indent)
+	for _, field := range fields {
+		fmt.Fprintf(&writer.buf, "%s%s?: never;\n", member_indent,
+			ts_property_name(field.JSONName))
+	}
+	fmt.Fprintf(&writer.buf, "%s  }\n", indent)
+}
+
+// Writes the declaration for an enum, as a union of the names of its values.
+func (writer *ts_writer) write_enum(
+	enum *docdata.EnumData,
+	indent string,
+	first bool,
+) bool {
+	if !first {
+		writer.buf.WriteString("\n")
+	}
+
+	writer.write_doc(indent, &enum.CommentData, is_deprecated(enum))
+	fmt.Fprintf(&writer.buf, "%sexport type %s =\n", indent, enum.Name)
+
+	inner := indent + TYPESCRIPT_INDENT
+	for i, value := range enum.Values {
+		writer.write_doc(inner, &value.CommentData, is_deprecated(value))
+		fmt.Fprintf(&writer.buf, "%s| %s", inner, ts_quote(value.Name))
+		if i == len(enum.Values)-1 {
+			writer.buf.WriteString(";")
+		}
+		writer.buf.WriteString("\n")
+	}
+	if len(enum.Values) == 0 {
+		fmt.Fprintf(&writer.buf, "%snever;\n", inner)
+	}
+
+	return false
+}
+
+// Writes a TSDoc comment with the description of an element and, if it is
+// deprecated, a `@deprecated` tag. Nothing is written if there is nothing to
+// say.
+func (writer *ts_writer) write_doc(
+	indent string,
+	comments *docdata.CommentData,
+	deprecated bool,
+) {
+	lines := make([]string, 0)
+	description := strings.TrimSpace(comments.Description)
+	if description != "" {
+		lines = append(lines, strings.Split(description, "\n")...)
+	}
+
+	if deprecated {
+		tag := "@deprecated"
+		if reasons := comments.Tags["deprecated"]; len(reasons) > 0 &&
+			reasons[0] != "" {
+			tag += " " + reasons[0]
+		}
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, strings.Split(tag, "\n")...)
+	}
+
+	switch len(lines) {
+	case 0:
+		return
+	case 1:
+		fmt.Fprintf(&writer.buf, "%s/** %s */\n", indent,
+			ts_comment_text(lines[0]))
+		return
+	}
+
+	fmt.Fprintf(&writer.buf, "%s/**\n", indent)
+	for _, line := range lines {
+		line = strings.TrimRight(ts_comment_text(line), " \t")
+		if line == "" {
+			fmt.Fprintf(&writer.buf, "%s *\n", indent)
+		} else {
+			fmt.Fprintf(&writer.buf, "%s * %s\n", indent, line)
+		}
+	}
+	fmt.Fprintf(&writer.buf, "%s */\n", indent)
+}
+
+// Returns the TypeScript type of a field.
+func (writer *ts_writer) field_type(field *docdata.FieldData) string {
+	if entry := writer.data.GetMapEntry(field); entry != nil {
+		value := entry.Fields[1]
+		return fmt.Sprintf("{ [key: string]: %s }",
+			writer.value_type(value.Kind, value.FullTypeName))
+	}
+
+	value_type := writer.value_type(field.Kind, field.FullTypeName)
+	if field.Label == "repeated" {
+		if strings.Contains(value_type, "|") {
+			value_type = "(" + value_type + ")"
+		}
+		return value_type + "[]"
+	}
+
+	return value_type
+}
+
+// Returns the TypeScript type of a value of the given kind (e.g., "int64" or
+// "message") and type. Messages and enums are referred to by their
+// fully-qualified names, and unknown types by `unknown`.
+func (writer *ts_writer) value_type(kind, full_type string) string {
+	full_type = strings.TrimPrefix(full_type, ".")
+
+	if well_known, ok := well_known_ts_types[full_type]; ok {
+		return well_known
+	}
+
+	switch kind {
+	case "message", "group":
+		if _, ok := writer.data.MessageMap[full_type]; ok {
+			return full_type
+		}
+		return "unknown"
+	case "enum":
+		if _, ok := writer.data.EnumMap[full_type]; ok {
+			return full_type
+		}
+		return "string"
+	}
+
+	return ts_scalar_type(kind)
+}
+
+// Returns the TypeScript type of a scalar. 64-bit integers are encoded as
+// strings, and floating point numbers can also be "NaN", "Infinity", or
+// "-Infinity". Bytes are encoded as base64 strings.
+func ts_scalar_type(kind string) string {
+	switch kind {
+	case "double", "float":
+		return `number | "NaN" | "Infinity" | "-Infinity"`
+	case "int64", "sint64", "sfixed64", "uint64", "fixed64":
+		return "string"
+	case "int32", "sint32", "sfixed32", "uint32", "fixed32":
+		return "number"
+	case "bool":
+		return "boolean"
+	case "string", "bytes":
+		return "string"
+	}
+
+	return "unknown"
+}
+
+// Returns a property name, quoted if it is not a valid identifier.
+func ts_property_name(name string) string {
+	if ts_identifier_re.MatchString(name) {
+		return name
+	}
+
+	return ts_quote(name)
+}
+
+// Returns a TypeScript string literal.
+func ts_quote(str string) string {
+	quoted, _ := json.Marshal(str)
+
+	return string(quoted)
+}
+
+// Keeps comment text from closing the comment early.
+func ts_comment_text(str string) string {
+	return strings.ReplaceAll(str, "*/", "*\\/")
+}
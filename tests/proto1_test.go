@@ -154,7 +154,7 @@ func do_check_services(t *testing.T, data map[string]any) {
 
 	label := fmt.Sprintf("service %s", svc["name"].(string))
 
-	exp_desc := "Tester service. Lorem ipsum dolor sit amet, consectetur adipiscing elit.\n Suspendisse a cursus mauris. Proin porta mi nisl, vel iaculis leo mattis\n ut. Maecenas lacus urna, dapibus sit amet leo id, rutrum fermentum justo.\n Cras porta, nulla vel euismod maximus, lacus magna ultrices metus, sit amet\n eleifend lacus libero et lacus. Cras a facilisis est. Praesent augue nisl,\n tincidunt vel ex mattis, efficitur fermentum sem. Ut congue tellus ut\n accumsan condimentum. Sed quis leo nec turpis maximus molestie quis sit\n amet erat."
+	exp_desc := "Tester service. Lorem ipsum dolor sit amet, consectetur adipiscing elit.\nSuspendisse a cursus mauris. Proin porta mi nisl, vel iaculis leo mattis\nut. Maecenas lacus urna, dapibus sit amet leo id, rutrum fermentum justo.\nCras porta, nulla vel euismod maximus, lacus magna ultrices metus, sit amet\neleifend lacus libero et lacus. Cras a facilisis est. Praesent augue nisl,\ntincidunt vel ex mattis, efficitur fermentum sem. Ut congue tellus ut\naccumsan condimentum. Sed quis leo nec turpis maximus molestie quis sit\namet erat."
 
 	desc := svc["description"].(string)
 	if desc != exp_desc {
@@ -288,7 +288,7 @@ func check_methods(t *testing.T, svc map[string]any) {
 		{
 			"name":       "RunTestV2",
 			"full_name":  "MyServices.Service.Tester.RunTestV2",
-			"desc":       "Leading comment for the RunTestV2 method which is marked not_implemented\n via a custom option method_not_implemented.",
+			"desc":       "Leading comment for the RunTestV2 method which is marked not_implemented\nvia a custom option method_not_implemented.",
 			"defined_in": "service-tester.proto",
 			"options": map[string]any{
 				"deprecated": false,
@@ -797,8 +797,8 @@ func do_check_syntax(t *testing.T, data map[string]any) {
 	test_spec_map := map[string]any{
 		"leading_comments":          "This is the syntax statement leading comment.",
 		"trailing_comments":         "This is a trailing comment for syntax.",
-		"description":               "This is the syntax statement leading comment. This is a trailing comment for syntax.",
-		"leading_detached_comments": []string{"Leading detached comment for the syntax statement.\n A second line."},
+		"description":               "This is the syntax statement leading comment.\n\nThis is a trailing comment for syntax.",
+		"leading_detached_comments": []string{"Leading detached comment for the syntax statement.\nA second line."},
 	}
 
 	check_comments(t, syntax_data, test_spec_map, field_desc)
@@ -0,0 +1,1908 @@
+package proto1_test
+
+import (
+	// Built-in/core modules.
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	exec "os/exec"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	// Third-party modules.
+	yaml "gopkg.in/yaml.v3"
+	// Generated code.
+	// First-party modules.
+)
+
+var PROTO2_FILES = []string{"features.proto"}
+
+func TestJSONMapping(t *testing.T) {
+	data, ok := do_setup_proto2(t, "")
+	if !ok {
+		return
+	}
+
+	msg_name := "Features.V1.JsonThing"
+	fields := get_fields_by_name(t, data, msg_name)
+	if fields == nil {
+		return
+	}
+
+	test_spec := map[string]map[string]any{
+		"big_number": {
+			"json_name":          "bigNumber",
+			"json_name_explicit": false,
+			"json_type":          "string",
+			"container":          "",
+			"value_encoding":     "int64_string",
+		},
+		"blob": {
+			"json_name":      "blob",
+			"json_type":      "string",
+			"value_encoding": "base64",
+		},
+		"color": {
+			"json_name":      "color",
+			"json_type":      "string",
+			"value_encoding": "enum_name",
+		},
+		"tag_list": {
+			"json_name":       "tagList",
+			"json_type":       "array",
+			"container":       "array",
+			"value_json_type": "string",
+		},
+		"counts": {
+			"json_name":       "counts",
+			"json_type":       "object",
+			"container":       "map",
+			"value_json_type": "string",
+			"value_encoding":  "int64_string",
+			"map_key_type":    "string",
+			"map_value_type":  "int64",
+		},
+		"renamed_field": {
+			"json_name":          "customName",
+			"json_name_explicit": true,
+			"json_type":          "string",
+		},
+		"created_at": {
+			"json_name":       "createdAt",
+			"json_type":       "string",
+			"value_encoding":  "timestamp",
+			"well_known_type": "google.protobuf.Timestamp",
+		},
+		"small_number": {
+			"json_name":      "smallNumber",
+			"json_type":      "number",
+			"value_encoding": "integer",
+		},
+	}
+
+	for _, field_name := range get_sorted_keys(to_any_map(test_spec)) {
+		field := fields[field_name]
+		if field == nil {
+			t.Errorf("missing field %s in %s", field_name, msg_name)
+			continue
+		}
+
+		mapping, ok := field["protojson"].(map[string]any)
+		if !ok {
+			t.Errorf("wrong type for protojson in field %s: %T", field_name,
+				field["protojson"])
+			continue
+		}
+
+		for key, exp_val := range test_spec[field_name] {
+			got_val := mapping[key]
+			if strings.HasPrefix(key, "json_name") {
+				got_val = field[key]
+			}
+			if !reflect.DeepEqual(got_val, exp_val) {
+				t.Errorf("incorrect %s for field %s: got %v, expected %v",
+					key, field_name, got_val, exp_val)
+			}
+		}
+	}
+}
+
+func to_any_map[V any](in_map map[string]V) map[string]any {
+	out_map := make(map[string]any, len(in_map))
+	for key, val := range in_map {
+		out_map[key] = val
+	}
+
+	return out_map
+}
+
+// Returns the fields of the given message, keyed by field name.
+func get_fields_by_name(
+	t *testing.T,
+	data map[string]any,
+	msg_name string,
+) map[string]map[string]any {
+	msg_map := data["message_map"].(map[string]any)
+	msg, ok := msg_map[msg_name].(map[string]any)
+	if !ok {
+		t.Errorf("missing message %s", msg_name)
+		return nil
+	}
+
+	fields := make(map[string]map[string]any)
+	for _, field_any := range msg["fields"].([]any) {
+		field := field_any.(map[string]any)
+		fields[field["name"].(string)] = field
+	}
+
+	return fields
+}
+
+// Runs the plugin over the files in the proto2 test directory with the given
+// plugin options and returns the decoded JSON output.
+func do_setup_proto2(t *testing.T, opts string) (map[string]any, bool) {
+	return do_setup_dir(t, "data/proto2", opts, PROTO2_FILES...)
+}
+
+// Runs the plugin over the given files in the given test directory with the
+// given plugin options and returns the decoded JSON output.
+func do_setup_dir(
+	t *testing.T,
+	proto_subdir, opts string,
+	files ...string,
+) (map[string]any, bool) {
+	out_file_name := "docs.json"
+	plugin_opts := "outfile=" + out_file_name
+	if opts != "" {
+		plugin_opts += "," + opts
+	}
+
+	out_dir, ok := run_plugin(t, proto_subdir, plugin_opts, files...)
+	if !ok {
+		return nil, false
+	}
+
+	json_bytes, err := os.ReadFile(path.Join(out_dir, out_file_name))
+	if err != nil {
+		t.Errorf("couldn't read JSON data: %s", err)
+		return nil, false
+	}
+
+	data := make(map[string]any)
+	if err = json.Unmarshal(json_bytes, &data); err != nil {
+		t.Errorf("couldn't unmarshal JSON file into data structure: %s", err)
+		return nil, false
+	}
+
+	return data, true
+}
+
+// Runs the protobuf compiler with the plugin over the given files, relative to
+// the given directory. Returns the output directory, which is removed once the
+// test completes.
+func run_plugin(
+	t *testing.T,
+	proto_subdir, plugin_opts string,
+	files ...string,
+) (string, bool) {
+	cur_dir, err := os.Getwd()
+	if err != nil {
+		t.Errorf("couldn't get working directory: %s", err)
+		return "", false
+	}
+
+	proto_dir := path.Join(cur_dir, proto_subdir)
+	bin_dir := path.Join(cur_dir, "../cmd/protoc-gen-docjson")
+	out_dir := t.TempDir()
+
+	args := []string{
+		fmt.Sprintf("PATH=%s:%s", bin_dir, os.Getenv("PATH")),
+		"protoc",
+		fmt.Sprintf("--docjson_out=%s", out_dir),
+		fmt.Sprintf("--docjson_opt=proto=%s,%s", proto_dir, plugin_opts),
+		fmt.Sprintf("-I%s", proto_dir),
+	}
+	args = append(args, files...)
+
+	cmd := exec.Command("/usr/bin/env", args...)
+	cmd.Dir = proto_dir
+	t.Logf("running cmd %s", cmd)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("protobuf compiler failed: %s: %s", err, output)
+		return "", false
+	}
+
+	return out_dir, true
+}
+
+// Runs the protobuf compiler with the plugin, expecting it to fail, and
+// returns the output.
+func run_plugin_expect_failure(
+	t *testing.T,
+	proto_subdir, plugin_opts string,
+	files ...string,
+) string {
+	cur_dir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("couldn't get working directory: %s", err)
+	}
+	bin_dir := path.Join(cur_dir, "../cmd/protoc-gen-docjson")
+
+	args := []string{
+		fmt.Sprintf("--docjson_out=%s", t.TempDir()),
+		fmt.Sprintf("--docjson_opt=%s", plugin_opts),
+		fmt.Sprintf("-I%s", proto_subdir),
+	}
+	cmd := exec.Command("protoc", append(args, files...)...)
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("PATH=%s:%s", bin_dir, os.Getenv("PATH")))
+
+	output, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Errorf("expected protobuf compiler to fail with %s", plugin_opts)
+	}
+
+	return string(output)
+}
+
+func TestStandardOptions(t *testing.T) {
+	data, ok := do_setup_proto2(t, "")
+	if !ok {
+		return
+	}
+
+	file_map := data["file_map"].(map[string]any)
+	file_data := file_map["features.proto"].(map[string]any)
+	file_opts := file_data["options"].(map[string]any)
+	check_fields_equal(t, file_opts, map[string]any{
+		"optimize_for":        float64(2),
+		"cc_generic_services": true,
+		"py_generic_services": false,
+	}, "file options for features.proto", nil)
+	check_fields_equal(t, file_opts["raw_options"].(map[string]any),
+		map[string]any{
+			"optimize_for":        "CODE_SIZE",
+			"cc_generic_services": true,
+		}, "raw file options for features.proto", nil)
+
+	fields := get_fields_by_name(t, data, "Features.V1.JsonThing")
+	if fields == nil {
+		return
+	}
+	field_opts := fields["secret"]["options"].(map[string]any)
+	check_fields_equal(t, field_opts, map[string]any{
+		"debug_redact": true,
+		"weak":         false,
+		"targets":      []any{},
+	}, "field options for secret", nil)
+
+	// Custom options are not known to the plugin, so they show up in the raw
+	// options keyed by field number.
+	raw_field_opts := field_opts["raw_options"].(map[string]any)
+	if _, ok := raw_field_opts["52001"]; !ok {
+		t.Errorf("missing custom option 52001 in raw options for secret: %v",
+			raw_field_opts)
+	}
+
+	ext_map := data["extension_map"].(map[string]any)
+	ext := ext_map["Features.V1.field_note"].(map[string]any)
+	check_fields_equal(t, ext["options"].(map[string]any), map[string]any{
+		"retention": float64(2),
+		"targets":   []any{float64(4)},
+	}, "extension options for field_note", nil)
+
+	svc_map := data["service_map"].(map[string]any)
+	svc := svc_map["Features.V1.ThingService"].(map[string]any)
+	method := svc["methods"].([]any)[0].(map[string]any)
+	check_fields_equal(t, method["options"].(map[string]any), map[string]any{
+		"idempotency_level": float64(1),
+	}, "method options for GetThing", nil)
+}
+
+func TestSourceLocations(t *testing.T) {
+	url_template := "https://git.example.com/repo/blob/{ref}/{file}#L{line}"
+	data, ok := do_setup_proto2(t,
+		"source_url_template="+url_template+",source_ref=v1.2.3")
+	if !ok {
+		return
+	}
+
+	msg_map := data["message_map"].(map[string]any)
+	msg := msg_map["Features.V1.JsonThing"].(map[string]any)
+	check_fields_equal(t, msg["source"].(map[string]any), map[string]any{
+		"file":         "features.proto",
+		"start_line":   float64(35),
+		"start_column": float64(1),
+		"url":          "https://git.example.com/repo/blob/v1.2.3/features.proto#L35",
+	}, "source for message JsonThing", nil)
+
+	fields := get_fields_by_name(t, data, "Features.V1.JsonThing")
+	if fields == nil {
+		return
+	}
+	check_fields_equal(t, fields["big_number"]["source"].(map[string]any),
+		map[string]any{
+			"start_line":   float64(36),
+			"start_column": float64(5),
+			"end_line":     float64(36),
+			"end_column":   float64(26),
+		}, "source for field big_number", nil)
+
+	svc_map := data["service_map"].(map[string]any)
+	svc := svc_map["Features.V1.ThingService"].(map[string]any)
+	method := svc["methods"].([]any)[0].(map[string]any)
+	check_fields_equal(t, method["source"].(map[string]any), map[string]any{
+		"start_line": float64(50),
+		"end_line":   float64(52),
+	}, "source for method GetThing", nil)
+}
+
+func TestDeclComments(t *testing.T) {
+	data, ok := do_setup_proto2(t, "")
+	if !ok {
+		return
+	}
+
+	file_map := data["file_map"].(map[string]any)
+	file_data := file_map["features.proto"].(map[string]any)
+
+	package_decl := file_data["package_decl"].(map[string]any)
+	check_fields_equal(t, package_decl, map[string]any{
+		"name":        "Features.V1",
+		"description": "Package overview for Features.V1.",
+	}, "package_decl", nil)
+
+	imports := file_data["imports"].([]any)
+	if len(imports) != 2 {
+		t.Fatalf("got %d imports, expected 2", len(imports))
+	}
+	check_fields_equal(t, imports[0].(map[string]any), map[string]any{
+		"name":        "google/protobuf/descriptor.proto",
+		"package":     "google.protobuf",
+		"description": "Needed for custom options.",
+		"public":      false,
+	}, "first import", nil)
+
+	option_decls := make(map[string]map[string]any)
+	for _, option_decl := range file_data["option_decls"].([]any) {
+		option_decl := option_decl.(map[string]any)
+		option_decls[option_decl["name"].(string)] = option_decl
+	}
+	check_fields_equal(t, option_decls["optimize_for"], map[string]any{
+		"description":  "Optimize for code size.",
+		"field_number": float64(9),
+		"custom":       false,
+	}, "optimize_for option_decl", nil)
+	check_fields_equal(t, option_decls["(Features.V1.file_label)"],
+		map[string]any{
+			"field_number": float64(52002),
+			"custom":       true,
+		}, "file_label option_decl", nil)
+
+	ext_map := data["extension_map"].(map[string]any)
+	ext := ext_map["Features.V1.field_note"].(map[string]any)
+	extend_decl, ok := ext["extend_decl"].(map[string]any)
+	if !ok {
+		t.Fatalf("wrong type for extend_decl: %T", ext["extend_decl"])
+	}
+	check_fields_equal(t, extend_decl, map[string]any{
+		"description": "Custom options for fields.",
+		"extendee":    ".google.protobuf.FieldOptions",
+	}, "extend_decl for field_note", nil)
+
+	ext = ext_map["Features.V1.file_label"].(map[string]any)
+	extend_decl = ext["extend_decl"].(map[string]any)
+	check_fields_equal(t, extend_decl, map[string]any{
+		"description": "",
+		"extendee":    ".google.protobuf.FileOptions",
+	}, "extend_decl for file_label", nil)
+}
+
+func TestPackageIndex(t *testing.T) {
+	data, ok := do_setup_proto2(t, "")
+	if !ok {
+		return
+	}
+
+	check_fields_equal(t, data, map[string]any{
+		"package_name_list": []any{"Features.V1"},
+	}, "top-level fields", nil)
+
+	pkg_map := data["package_map"].(map[string]any)
+	pkg, ok := pkg_map["Features.V1"].(map[string]any)
+	if !ok {
+		t.Fatalf("missing package Features.V1 in package_map")
+	}
+
+	check_fields_equal(t, pkg, map[string]any{
+		"name":        "Features.V1",
+		"description": "Package overview for Features.V1.",
+		"files":       []any{"features.proto"},
+		"messages": []any{
+			"Features.V1.JsonThing",
+			"Features.V1.JsonThing.CountsEntry",
+		},
+		"enums":    []any{"Features.V1.Color"},
+		"services": []any{"Features.V1.ThingService"},
+		"extensions": []any{
+			"Features.V1.field_note",
+			"Features.V1.file_label",
+		},
+		"imports": []any{"google.protobuf"},
+		"external_imports": []any{
+			"google/protobuf/descriptor.proto",
+			"google/protobuf/timestamp.proto",
+		},
+	}, "package Features.V1", nil)
+}
+
+func TestDocTags(t *testing.T) {
+	data, ok := do_setup_dir(t, "data/tags", "", "tags.proto")
+	if !ok {
+		return
+	}
+
+	msg_map := data["message_map"].(map[string]any)
+	msg := msg_map["Tags.V1.Widget"].(map[string]any)
+	check_fields_equal(t, msg, map[string]any{
+		"description": "A widget.\n\nWidgets are described here.",
+		"tags": map[string]any{
+			"since":   []any{"v2.3"},
+			"see":     []any{"Tags.V1.Gadget", "Tags.V1.Sprocket"},
+			"example": []any{"{\n  \"name\": \"foo\"\n}"},
+		},
+	}, "message Widget", nil)
+
+	fields := get_fields_by_name(t, data, "Tags.V1.Widget")
+	if fields == nil {
+		return
+	}
+	check_fields_equal(t, fields["name"], map[string]any{
+		"description": "The widget name.",
+		"tags": map[string]any{
+			"deprecated": []any{"use display_name instead"},
+		},
+	}, "field name", nil)
+	check_fields_equal(t, fields["display_name"], map[string]any{
+		"description": "The display name. @since is not a tag in the middle " +
+			"of a line.",
+		"tags": map[string]any{"since": []any{"v2.4"}},
+	}, "field display_name", nil)
+	check_fields_equal(t, fields["email"], map[string]any{
+		"description": "Contact address.\n@owner team-a",
+		"tags":        map[string]any{},
+	}, "field email", nil)
+
+	// A blank line inside an example doesn't end the tag, and the
+	// indentation of the example is kept.
+	example := "{\n  \"x\": \"a\",\n\n  \"y\": \"b\"\n}"
+	part := msg_map["Tags.V1.Part"].(map[string]any)
+	check_fields_equal(t, part, map[string]any{
+		"description": "A part.\n\nMore about parts.",
+		"tags":        map[string]any{"example": []any{example}},
+	}, "message Part", nil)
+	examples := part["examples"].([]any)
+	if len(examples) != 1 {
+		t.Fatalf("got %d examples for Part, expected 1", len(examples))
+	}
+	check_fields_equal(t, examples[0].(map[string]any), map[string]any{
+		"language": "json",
+		"body":     example,
+	}, "example for Part", nil)
+	for _, warning := range data["warnings"].([]any) {
+		t.Errorf("unexpected warning: %v", warning)
+	}
+
+	data, ok = do_setup_dir(t, "data/tags", "doc_tags=owner:since",
+		"tags.proto")
+	if !ok {
+		return
+	}
+	fields = get_fields_by_name(t, data, "Tags.V1.Widget")
+	check_fields_equal(t, fields["email"], map[string]any{
+		"description": "Contact address.",
+		"tags":        map[string]any{"owner": []any{"team-a"}},
+	}, "field email with doc_tags option", nil)
+	check_fields_equal(t, fields["name"], map[string]any{
+		"description": "The widget name.\n@deprecated use display_name instead",
+	}, "field name with doc_tags option", nil)
+}
+
+func TestMarkdownDescriptions(t *testing.T) {
+	data, ok := do_setup_dir(t, "data/markdown", "", "markdown.proto")
+	if !ok {
+		return
+	}
+
+	msg_map := data["message_map"].(map[string]any)
+	msg := msg_map["Markdown.V1.Document"].(map[string]any)
+	for _, key := range []string{"description_html", "description_blocks"} {
+		if _, ok := msg[key]; ok {
+			t.Errorf("%s should only be set with the markdown option", key)
+		}
+	}
+
+	data, ok = do_setup_dir(t, "data/markdown", "markdown", "markdown.proto")
+	if !ok {
+		return
+	}
+
+	msg_map = data["message_map"].(map[string]any)
+	msg = msg_map["Markdown.V1.Document"].(map[string]any)
+
+	desc_html, _ := msg["description_html"].(string)
+	for _, exp_html := range []string{
+		"<h1>Overview</h1>",
+		"<em>document</em>",
+		`<a href="https://example.com">link</a>`,
+		"<table>",
+	} {
+		if !strings.Contains(desc_html, exp_html) {
+			t.Errorf("description_html is missing %q: %s", exp_html, desc_html)
+		}
+	}
+	for _, bad_html := range []string{"<script>", "javascript:"} {
+		if strings.Contains(desc_html, bad_html) {
+			t.Errorf("description_html contains %q: %s", bad_html, desc_html)
+		}
+	}
+
+	blocks := msg["description_blocks"].([]any)
+	block_types := make([]any, 0, len(blocks))
+	for _, block := range blocks {
+		block_types = append(block_types, block.(map[string]any)["type"])
+	}
+	check_fields_equal(t, map[string]any{"types": block_types},
+		map[string]any{
+			"types": []any{
+				"heading", "paragraph", "list", "code", "table", "paragraph",
+			},
+		}, "description block types", nil)
+
+	check_fields_equal(t, blocks[0].(map[string]any), map[string]any{
+		"text":  "Overview",
+		"level": float64(1),
+	}, "heading block", nil)
+	check_fields_equal(t, blocks[1].(map[string]any), map[string]any{
+		"text": "A document with code and a link.",
+	}, "paragraph block", nil)
+	check_fields_equal(t, blocks[2].(map[string]any), map[string]any{
+		"items": []any{"first item", "second item"},
+	}, "list block", nil)
+	check_fields_equal(t, blocks[3].(map[string]any), map[string]any{
+		"language": "json",
+		"code":     `{"name": "foo"}`,
+	}, "code block", nil)
+	check_fields_equal(t, blocks[4].(map[string]any), map[string]any{
+		"header": []any{"Name", "Value"},
+		"rows":   []any{[]any{"a", "1"}},
+	}, "table block", nil)
+}
+
+func TestCommentNormalization(t *testing.T) {
+	data, ok := do_setup_dir(t, "data/comments", "", "comments.proto")
+	if !ok {
+		return
+	}
+
+	msg_map := data["message_map"].(map[string]any)
+	msg := msg_map["Comments.V1.Block"].(map[string]any)
+	check_fields_equal(t, msg, map[string]any{
+		"description": "A block comment with a gutter.\n\nExample:\n\n" +
+			"    {\n      \"name\": \"foo\"\n    }",
+	}, "message Block", nil)
+
+	fields := get_fields_by_name(t, data, "Comments.V1.Block")
+	if fields == nil {
+		return
+	}
+	check_fields_equal(t, fields["name"], map[string]any{
+		"leading_comments":  "First paragraph.\n\nSecond paragraph.",
+		"trailing_comments": "Trailing comment.",
+		"description": "First paragraph.\n\nSecond paragraph.\n\n" +
+			"Trailing comment.",
+	}, "field name", nil)
+	check_fields_equal(t, fields["name"]["raw_comments"].(map[string]any),
+		map[string]any{
+			"leading":  " First paragraph.\n\n\n Second paragraph.\n",
+			"trailing": " Trailing comment.\n",
+		}, "raw comments for field name", nil)
+
+	data, ok = do_setup_dir(t, "data/comments", "comment_separator= - ",
+		"comments.proto")
+	if !ok {
+		return
+	}
+	fields = get_fields_by_name(t, data, "Comments.V1.Block")
+	check_fields_equal(t, fields["name"], map[string]any{
+		"description": "First paragraph.\n\nSecond paragraph. - " +
+			"Trailing comment.",
+	}, "field name with comment_separator", nil)
+}
+
+func TestSummaries(t *testing.T) {
+	data, ok := do_setup_dir(t, "data/tags", "", "tags.proto")
+	if !ok {
+		return
+	}
+
+	msg_map := data["message_map"].(map[string]any)
+	test_spec := map[string]string{
+		"Tags.V1.Widget": "A widget.",
+		"Tags.V1.Gadget": "Gadget summary from the tag.",
+		"Tags.V1.Sprocket": "Sprockets, as specified by J. R. Smith, are " +
+			"toothed wheels that mesh with a chain, track, or other " +
+			"perforated or...",
+	}
+	for _, msg_name := range get_sorted_keys(to_any_map(test_spec)) {
+		msg := msg_map[msg_name].(map[string]any)
+		check_fields_equal(t, msg, map[string]any{
+			"summary": test_spec[msg_name],
+		}, "message "+msg_name, nil)
+	}
+
+	fields := get_fields_by_name(t, data, "Tags.V1.Widget")
+	if fields == nil {
+		return
+	}
+	check_fields_equal(t, fields["display_name"], map[string]any{
+		"summary": "The display name.",
+	}, "field display_name", nil)
+
+	// Summaries only come from the leading comment, even when the trailing
+	// comment is joined to it on the same line.
+	data, ok = do_setup_dir(t, "data/tags", "comment_separator= ",
+		"tags.proto")
+	if !ok {
+		return
+	}
+	fields = get_fields_by_name(t, data, "Tags.V1.Part")
+	check_fields_equal(t, fields["x"], map[string]any{
+		"description": "Only a trailing comment.",
+		"summary":     "",
+	}, "field x", nil)
+	check_fields_equal(t, fields["y"], map[string]any{
+		"description": "The y value with a trailing comment.",
+		"summary":     "The y value",
+	}, "field y", nil)
+
+	data, ok = do_setup_dir(t, "data/tags", "summary_width=40", "tags.proto")
+	if !ok {
+		return
+	}
+	msg_map = data["message_map"].(map[string]any)
+	msg := msg_map["Tags.V1.Sprocket"].(map[string]any)
+	check_fields_equal(t, msg, map[string]any{
+		"summary": "Sprockets, as specified by J. R....",
+	}, "message Sprocket with summary_width", nil)
+}
+
+func TestCommentLinks(t *testing.T) {
+	data, ok := do_setup_dir(t, "data/links", "", "links.proto")
+	if !ok {
+		return
+	}
+
+	get_links := func(comments map[string]any) map[string]string {
+		links := make(map[string]string)
+		for _, link_any := range comments["links"].([]any) {
+			link := link_any.(map[string]any)
+			links[link["text"].(string)] =
+				link["target"].(string) + " " + link["kind"].(string)
+		}
+		return links
+	}
+
+	msg_map := data["message_map"].(map[string]any)
+	msg := msg_map["Links.V1.Request"].(map[string]any)
+	check_fields_equal(t, map[string]any{"links": get_links(msg)},
+		map[string]any{
+			"links": map[string]string{
+				"[Response]":               "Links.V1.Response message",
+				"{@link Status.STATUS_OK}": "Links.V1.Status.STATUS_OK enum_value",
+				"[Response.items]":         "Links.V1.Response.items field",
+				"[.Links.V1.Lookup]":       "Links.V1.Lookup service",
+				"Links.V1.Lookup.Get":      "Links.V1.Lookup.Get method",
+			},
+		}, "links for message Request", nil)
+
+	// References in code blocks and code spans are skipped, without
+	// warnings.
+	msg = msg_map["Links.V1.Response"].(map[string]any)
+	check_fields_equal(t, map[string]any{"links": get_links(msg)},
+		map[string]any{"links": map[string]string{}},
+		"links for message Response", nil)
+
+	fields := get_fields_by_name(t, data, "Links.V1.Request")
+	if fields == nil {
+		return
+	}
+	check_fields_equal(t, map[string]any{"links": get_links(fields["filter"])},
+		map[string]any{
+			"links": map[string]string{
+				"[Filter]": "Links.V1.Request.Filter message",
+			},
+		}, "links for field filter", nil)
+
+	msg = msg_map["Links.V1.Request.Filter"].(map[string]any)
+	check_fields_equal(t, map[string]any{"links": get_links(msg)},
+		map[string]any{
+			"links": map[string]string{
+				"[Request]": "Links.V1.Request message",
+				"[filter]":  "Links.V1.Request.filter field",
+			},
+		}, "links for message Filter", nil)
+
+	enum_map := data["enum_map"].(map[string]any)
+	enum_data := enum_map["Links.V1.Status"].(map[string]any)
+	enum_val := enum_data["values"].([]any)[1].(map[string]any)
+	check_fields_equal(t, map[string]any{"links": get_links(enum_val)},
+		map[string]any{
+			"links": map[string]string{
+				"[STATUS_UNSPECIFIED]": "Links.V1.Status.STATUS_UNSPECIFIED enum_value",
+			},
+		}, "links for enum value STATUS_OK", nil)
+
+	warnings := data["warnings"].([]any)
+	if len(warnings) != 1 {
+		t.Fatalf("got %d warnings, expected 1: %v", len(warnings), warnings)
+	}
+	check_fields_equal(t, warnings[0].(map[string]any), map[string]any{
+		"code":    "unresolved_link",
+		"message": "couldn't resolve reference [Missing]",
+		"file":    "links.proto",
+		"element": "Links.V1.Request.filter",
+	}, "unresolved link warning", nil)
+
+	output := run_plugin_expect_failure(t, "data/links", "fail_on_warnings",
+		"links.proto")
+	if !strings.Contains(output, "[Missing]") {
+		t.Errorf("missing warning in error output: %s", output)
+	}
+}
+
+func TestVisibilityFiltering(t *testing.T) {
+	data, ok := do_setup_dir(t, "data/visibility",
+		"hide_option=(Visibility.V1.visibility)=INTERNAL,"+
+			"hide_option=msg_visibility=INTERNAL,hide_option=internal_method",
+		"visibility.proto")
+	if !ok {
+		return
+	}
+
+	for _, list_name := range []string{
+		"message_name_list",
+		"enum_name_list",
+	} {
+		for _, name := range data[list_name].([]any) {
+			if strings.HasPrefix(name.(string), "Visibility.V1.Secret") ||
+				name == "Visibility.V1.Status" {
+				t.Errorf("hidden element %s in %s", name, list_name)
+			}
+		}
+	}
+
+	msg_map := data["message_map"].(map[string]any)
+	for _, name := range []string{
+		"Visibility.V1.Secret",
+		"Visibility.V1.Secret.Nested",
+	} {
+		if _, ok := msg_map[name]; ok {
+			t.Errorf("hidden message %s in message_map", name)
+		}
+	}
+	if _, ok := msg_map["Visibility.V1.Response"]; !ok {
+		t.Errorf("public message Response missing from message_map")
+	}
+	if _, ok := data["enum_map"].(map[string]any)["Visibility.V1.Status"]; ok {
+		t.Errorf("hidden enum Status in enum_map")
+	}
+
+	fields := get_fields_by_name(t, data, "Visibility.V1.Request")
+	if fields == nil {
+		return
+	}
+	if _, ok := fields["debug_info"]; ok {
+		t.Errorf("hidden field debug_info in Request")
+	}
+	for _, name := range []string{"name", "secret", "status"} {
+		if _, ok := fields[name]; !ok {
+			t.Errorf("visible field %s missing from Request", name)
+		}
+	}
+
+	svc_map := data["service_map"].(map[string]any)
+	svc := svc_map["Visibility.V1.Api"].(map[string]any)
+	method_names := make([]any, 0)
+	for _, method := range svc["methods"].([]any) {
+		method_names = append(method_names, method.(map[string]any)["name"])
+	}
+	check_fields_equal(t, map[string]any{"methods": method_names},
+		map[string]any{"methods": []any{"Get", "Other"}}, "methods for Api",
+		nil)
+
+	deps := data["message_deps"].(map[string]any)["Visibility.V1.Request"]
+	for _, dep := range deps.([]any) {
+		if dep == "Visibility.V1.Secret" || dep == "Visibility.V1.Status" {
+			t.Errorf("hidden type %s in message_deps for Request", dep)
+		}
+	}
+
+	warnings := make(map[string]bool)
+	for _, warning := range data["warnings"].([]any) {
+		warning := warning.(map[string]any)
+		warnings[warning["code"].(string)+" "+warning["element"].(string)] =
+			true
+	}
+	check_fields_equal(t, map[string]any{"warnings": warnings},
+		map[string]any{
+			"warnings": map[string]bool{
+				"hidden_type_reference Visibility.V1.Request.secret": true,
+				"hidden_type_reference Visibility.V1.Request.status": true,
+				"hidden_link Visibility.V1.Request":                  true,
+			},
+		}, "warnings", nil)
+
+	data, ok = do_setup_dir(t, "data/visibility", "hide_tag=",
+		"visibility.proto")
+	if !ok {
+		return
+	}
+	if _, ok := data["enum_map"].(map[string]any)["Visibility.V1.Status"]; !ok {
+		t.Errorf("enum Status hidden with hide_tag set to nothing")
+	}
+}
+
+func TestExamples(t *testing.T) {
+	data, ok := do_setup_dir(t, "data/examples", "", "examples.proto")
+	if !ok {
+		return
+	}
+
+	msg_map := data["message_map"].(map[string]any)
+	msg := msg_map["Examples.V1.Order"].(map[string]any)
+	examples := msg["examples"].([]any)
+	if len(examples) != 5 {
+		t.Fatalf("got %d examples, expected 5", len(examples))
+	}
+
+	test_spec := []map[string]any{
+		{
+			"language": "json",
+			"title":    "Minimal order",
+			"origin":   "code_block",
+			"body": `{"order_id": "a1", "lineItems": [{"sku": "x", ` +
+				`"qty": 2}]}`,
+		},
+		{"language": "json", "title": "Stale order", "origin": "code_block"},
+		{
+			"language": "sh",
+			"title":    "",
+			"body":     "curl https://example.com/orders",
+		},
+		{
+			"language": "json",
+			"title":    "Order with labels",
+			"origin":   "tag",
+			"body":     `{"labels": {"a": {"sku": "y"}}}`,
+		},
+		{"language": "", "title": "", "body": `{"order_id": }`},
+	}
+	for i, exp_example := range test_spec {
+		check_fields_equal(t, examples[i].(map[string]any), exp_example,
+			fmt.Sprintf("example %d", i+1), nil)
+	}
+
+	warnings := make([]any, 0)
+	for _, warning := range data["warnings"].([]any) {
+		warning := warning.(map[string]any)
+		warnings = append(warnings,
+			warning["code"].(string)+": "+warning["message"].(string))
+	}
+	check_fields_equal(t, map[string]any{"warnings": warnings},
+		map[string]any{
+			"warnings": []any{
+				`stale_example: example "Stale order": unknown field ` +
+					`customer in Examples.V1.Order`,
+				`stale_example: example "Stale order": unknown field ` +
+					`line_items[0].count in Examples.V1.LineItem`,
+			},
+		}, "warnings", nil)
+}
+
+func TestTodoList(t *testing.T) {
+	data, ok := do_setup_dir(t, "data/todos", "", "stable.proto",
+		"draft.proto")
+	if !ok {
+		return
+	}
+
+	todos := data["todo_list"].([]any)
+	if len(todos) != 3 {
+		t.Fatalf("got %d todos, expected 3: %v", len(todos), todos)
+	}
+	check_fields_equal(t, todos[0].(map[string]any), map[string]any{
+		"marker":  "TODO",
+		"owner":   "alice",
+		"text":    "document the fields.",
+		"element": "Todos.Stable.Thing",
+		"kind":    "message",
+		"file":    "stable.proto",
+		"stable":  true,
+	}, "first todo", nil)
+	check_fields_equal(t, todos[1].(map[string]any), map[string]any{
+		"marker":  "FIXME",
+		"owner":   "",
+		"text":    "should be required.",
+		"element": "Todos.Stable.Thing.name",
+		"kind":    "field",
+	}, "second todo", nil)
+	check_fields_equal(t, todos[2].(map[string]any), map[string]any{
+		"marker":  "XXX",
+		"text":    "is this needed?",
+		"element": "Todos.Draft.Draft",
+		"file":    "draft.proto",
+		"stable":  false,
+	}, "third todo", nil)
+
+	data, ok = do_setup_dir(t, "data/todos", "stable_file=draft*.proto",
+		"stable.proto", "draft.proto")
+	if !ok {
+		return
+	}
+	file_map := data["file_map"].(map[string]any)
+	check_fields_equal(t, file_map["draft.proto"].(map[string]any),
+		map[string]any{"stable": true}, "draft.proto with stable_file", nil)
+
+	output := run_plugin_expect_failure(t, "data/todos",
+		"fail_on_stable_todos", "stable.proto", "draft.proto")
+	if !strings.Contains(output, "found 2 notes") {
+		t.Errorf("unexpected error output: %s", output)
+	}
+}
+
+func TestOverlays(t *testing.T) {
+	data, ok := do_setup_dir(t, "data/overlay", "overlay=overlay.yaml",
+		"vendor.proto")
+	if !ok {
+		return
+	}
+
+	widget := data["message_map"].(map[string]any)["Vendor.Api.Widget"].(map[string]any)
+	check_fields_equal(t, widget, map[string]any{
+		"description": "A widget from the vendor API.\n\n" +
+			"Widgets are returned by [Shape] lookups.",
+		"summary": "A widget from the vendor API.",
+	}, "Widget", nil)
+
+	tags := widget["tags"].(map[string]any)
+	if fmt.Sprint(tags["since"]) != "[v2.0]" {
+		t.Errorf("got since tag %v, expected [v2.0]", tags["since"])
+	}
+
+	links := widget["links"].([]any)
+	if len(links) != 1 ||
+		links[0].(map[string]any)["target"] != "Vendor.Api.Shape" {
+		t.Errorf("unexpected links for Widget: %v", links)
+	}
+
+	examples := widget["examples"].([]any)
+	if len(examples) != 1 {
+		t.Fatalf("got %d examples for Widget, expected 1", len(examples))
+	}
+	check_fields_equal(t, examples[0].(map[string]any), map[string]any{
+		"language": "json",
+		"title":    "Minimal widget",
+		"origin":   "overlay",
+	}, "Widget example", nil)
+
+	fields := get_fields_by_name(t, data, "Vendor.Api.Widget")
+	if fields == nil {
+		return
+	}
+	check_fields_equal(t, fields["id"], map[string]any{
+		"description": "Unique ID of the widget.",
+	}, "field id", nil)
+	if _, ok := fields["revision"]; !ok {
+		t.Errorf("field revision was hidden despite the overlay")
+	}
+	if _, ok := fields["debug_info"]; ok {
+		t.Errorf("field debug_info wasn't hidden by the overlay")
+	}
+
+	shape := data["enum_map"].(map[string]any)["Vendor.Api.Shape"].(map[string]any)
+	for _, value := range shape["values"].([]any) {
+		value_map := value.(map[string]any)
+		if value_map["name"] == "ROUND" {
+			check_fields_equal(t, value_map, map[string]any{
+				"description": "A round widget.",
+			}, "enum value ROUND", nil)
+		}
+	}
+
+	warnings := make(map[string]string)
+	for _, warning := range data["warnings"].([]any) {
+		warning_map := warning.(map[string]any)
+		warnings[warning_map["element"].(string)] =
+			warning_map["code"].(string)
+	}
+	if warnings["Vendor.Api.Gadget"] != "unknown_overlay_element" {
+		t.Errorf("missing unknown_overlay_element warning: %v", warnings)
+	}
+	if warnings["Vendor.Api.Widget"] != "stale_example" {
+		t.Errorf("missing stale_example warning for overlay example: %v",
+			warnings)
+	}
+
+	output := run_plugin_expect_failure(t, "data/overlay",
+		"overlay=missing.yaml", "vendor.proto")
+	if !strings.Contains(output, "couldn't read overlay file") {
+		t.Errorf("unexpected error output: %s", output)
+	}
+}
+
+func TestCommentVars(t *testing.T) {
+	data, ok := do_setup_dir(t, "data/vars",
+		"vars_file=vars.yaml,var=api_version=2.1", "vars.proto")
+	if !ok {
+		return
+	}
+
+	thing := data["message_map"].(map[string]any)["Vars.V1.Thing"].(map[string]any)
+	check_fields_equal(t, thing, map[string]any{
+		"description": "The Widget Store API, version 2.1.\n\n" +
+			"Requests are sent to https://api.example.com/v1/things. " +
+			"Write ${name} for a literal\nplaceholder.",
+		"summary": "The Widget Store API, version 2.1.",
+	}, "Thing", nil)
+
+	raw_comments := thing["raw_comments"].(map[string]any)
+	if !strings.Contains(raw_comments["leading"].(string), "${product}") {
+		t.Errorf("raw comments were expanded: %q", raw_comments["leading"])
+	}
+
+	fields := get_fields_by_name(t, data, "Vars.V1.Thing")
+	if fields == nil {
+		return
+	}
+	check_fields_equal(t, fields["name"], map[string]any{
+		"description": "Not documented until .",
+	}, "field name", nil)
+
+	warnings := data["warnings"].([]any)
+	if len(warnings) != 1 {
+		t.Fatalf("got %d warnings, expected 1: %v", len(warnings), warnings)
+	}
+	check_fields_equal(t, warnings[0].(map[string]any), map[string]any{
+		"code":    "undefined_variable",
+		"element": "Vars.V1.Thing.name",
+		"message": "variable ${missing_var} is not defined",
+	}, "warning", nil)
+}
+
+func TestTemplates(t *testing.T) {
+	out_dir, ok := run_plugin(t, "data/templates",
+		"outfile=docs.json,template=summary.md.tmpl,"+
+			"template=index.tmpl:index.html", "shop.proto")
+	if !ok {
+		return
+	}
+
+	summary, err := os.ReadFile(path.Join(out_dir, "summary.md"))
+	if err != nil {
+		t.Fatalf("couldn't read rendered template: %s", err)
+	}
+	expected := "## Item {#Shop-V1-Item}\n\n" +
+		"An item for sale, such as a <b>widget</b>.\n\n" +
+		"* 1: `price_cents`\n" +
+		"* 2: `name`\n" +
+		"Method: Looks up an item.\n" +
+		"    code\n    block\n" +
+		"Packages: Shop.V1\n"
+	if string(summary) != expected {
+		t.Errorf("got rendered template %q, expected %q", summary, expected)
+	}
+
+	index, err := os.ReadFile(path.Join(out_dir, "index.html"))
+	if err != nil {
+		t.Fatalf("couldn't read rendered template: %s", err)
+	}
+	if !strings.Contains(string(index), "&lt;b&gt;widget&lt;/b&gt;") {
+		t.Errorf("HTML template output wasn't escaped: %s", index)
+	}
+
+	output := run_plugin_expect_failure(t, "data/templates",
+		"template=missing.tmpl", "shop.proto")
+	if !strings.Contains(output, "couldn't read template") {
+		t.Errorf("unexpected error output: %s", output)
+	}
+}
+
+func TestMarkdownOutput(t *testing.T) {
+	out_dir, ok := run_plugin(t, "data/templates", "outfmt=markdown",
+		"shop.proto")
+	if !ok {
+		return
+	}
+
+	content, err := os.ReadFile(path.Join(out_dir, "Shop.V1.md"))
+	if err != nil {
+		t.Fatalf("couldn't read Markdown output: %s", err)
+	}
+	for _, expected := range []string{
+		"# Shop.V1\n",
+		"* [Services](#services)\n  * [ItemService](#Shop-V1-ItemService)\n",
+		"<a id=\"Shop-V1-Item\"></a>\n### Item\n",
+		"| <a id=\"Shop-V1-ItemService-GetItem\"></a>`GetItem` | " +
+			"[Item](#Shop-V1-Item) | [Item](#Shop-V1-Item) | " +
+			"Looks up an item. |\n",
+		"| <a id=\"Shop-V1-Cart-items\"></a>`items` | 1 | " +
+			"map\\<`string`, [Item](#Shop-V1-Item)\\> | map |  | " +
+			"Items by SKU. |\n",
+		"| <a id=\"Shop-V1-Cart-sizes\"></a>`sizes` | 2 | " +
+			"[Size](#Shop-V1-Size) | repeated | Yes | **Deprecated.** |\n",
+		"| <a id=\"Shop-V1-Size-LARGE\"></a>`LARGE` | 1 | **Deprecated.** " +
+			"Deprecated: use a custom size. |\n",
+	} {
+		if !strings.Contains(string(content), expected) {
+			t.Errorf("Markdown output is missing %q:\n%s", expected, content)
+		}
+	}
+	if strings.Contains(string(content), "ItemsEntry") {
+		t.Errorf("Markdown output includes map entry messages:\n%s", content)
+	}
+
+	out_dir, ok = run_plugin(t, "data/templates",
+		"outfmt=markdown,split_by=file,template_dir=override", "shop.proto")
+	if !ok {
+		return
+	}
+	content, err = os.ReadFile(path.Join(out_dir, "shop.md"))
+	if err != nil {
+		t.Fatalf("couldn't read Markdown output split by file: %s", err)
+	}
+	if !strings.Contains(string(content), "## Enums\n\nCustom enum Size\n") {
+		t.Errorf("template_dir override wasn't used:\n%s", content)
+	}
+}
+
+func TestHTMLOutput(t *testing.T) {
+	out_dir, ok := run_plugin(t, "data/templates", "outfmt=html",
+		"shop.proto")
+	if !ok {
+		return
+	}
+
+	read_output := func(file_name string) string {
+		content, err := os.ReadFile(path.Join(out_dir, file_name))
+		if err != nil {
+			t.Fatalf("couldn't read HTML output %s: %s", file_name, err)
+		}
+		return string(content)
+	}
+
+	page := read_output("Shop.V1.html")
+	for _, expected := range []string{
+		`<section class="message" id="Shop-V1-Item">`,
+		`<tr id="Shop-V1-Item-price_cents">`,
+		`<tr id="Shop-V1-ItemService-GetItem">`,
+		`<tr id="Shop-V1-Cart-sizes"><td><code>sizes</code> ` +
+			`<span class="badge deprecated">deprecated</span>`,
+		`<p>An item for sale, such as a &lt;b&gt;widget&lt;/b&gt;.</p>`,
+		`map&lt;<code>string</code>, <a href="#Shop-V1-Item">` +
+			`<code>Item</code></a>&gt;`,
+		`<script src="search_index.js"></script>`,
+	} {
+		if !strings.Contains(page, expected) {
+			t.Errorf("HTML page is missing %q", expected)
+		}
+	}
+	if strings.Contains(page, "http://") || strings.Contains(page, "https://") {
+		t.Errorf("HTML page refers to external assets")
+	}
+
+	index := read_output("index.html")
+	if !strings.Contains(index, `<a href="Shop.V1.html">Shop.V1</a>`) {
+		t.Errorf("index page is missing the link to Shop.V1:\n%s", index)
+	}
+
+	search_index := read_output("search_index.js")
+	for _, expected := range []string{
+		`{"name":"Shop.V1.ItemService.GetItem","kind":"method",` +
+			`"url":"Shop.V1.html#Shop-V1-ItemService-GetItem",` +
+			`"summary":"Looks up an item."}`,
+		`{"name":"Shop.V1.Size","kind":"enum",` +
+			`"url":"Shop.V1.html#Shop-V1-Size","summary":"Sizes of items."}`,
+	} {
+		if !strings.Contains(search_index, expected) {
+			t.Errorf("search index is missing %s:\n%s", expected,
+				search_index)
+		}
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	out_dir, ok := run_plugin(t, "data/openapi",
+		"outfmt=openapi,api_title=Library API,api_version=v1",
+		"library.proto")
+	if !ok {
+		return
+	}
+
+	json_bytes, err := os.ReadFile(path.Join(out_dir, "openapi.json"))
+	if err != nil {
+		t.Fatalf("couldn't read OpenAPI output: %s", err)
+	}
+	doc := make(map[string]any)
+	if err := json.Unmarshal(json_bytes, &doc); err != nil {
+		t.Fatalf("couldn't unmarshal OpenAPI output: %s", err)
+	}
+
+	if doc["openapi"] != "3.1.0" {
+		t.Errorf("unexpected OpenAPI version %v", doc["openapi"])
+	}
+	info := doc["info"].(map[string]any)
+	if info["title"] != "Library API" || info["version"] != "v1" {
+		t.Errorf("unexpected info object: %v", info)
+	}
+
+	paths := doc["paths"].(map[string]any)
+	if len(paths) != 4 {
+		t.Errorf("expected 4 paths, got %d: %v", len(paths), paths)
+	}
+
+	get_op := func(op_path, method string) map[string]any {
+		path_item, ok := paths[op_path].(map[string]any)
+		if !ok {
+			t.Fatalf("path %s is missing", op_path)
+		}
+		op, ok := path_item[method].(map[string]any)
+		if !ok {
+			t.Fatalf("operation %s %s is missing", method, op_path)
+		}
+		return op
+	}
+	get_params := func(op map[string]any) map[string]string {
+		params := make(map[string]string)
+		for _, param := range op["parameters"].([]any) {
+			param := param.(map[string]any)
+			params[param["name"].(string)] = param["in"].(string)
+		}
+		return params
+	}
+
+	dig := func(value map[string]any, keys ...string) map[string]any {
+		for _, key := range keys {
+			value, _ = value[key].(map[string]any)
+		}
+		return value
+	}
+
+	get_book := get_op("/v1/shelves/{shelf}/books/{book}", "get")
+	if get_book["operationId"] != "LibraryService_GetBook" {
+		t.Errorf("unexpected operationId %v", get_book["operationId"])
+	}
+	if !reflect.DeepEqual(get_params(get_book),
+		map[string]string{"shelf": "path", "book": "path",
+			"fullText": "query"}) {
+		t.Errorf("unexpected GetBook parameters: %v", get_params(get_book))
+	}
+
+	// Variables with different patterns are different paths.
+	get_shelf := get_op("/v1/shelves/{shelf}", "get")
+	if get_shelf["operationId"] != "LibraryService_GetShelf" {
+		t.Errorf("unexpected operationId %v", get_shelf["operationId"])
+	}
+	shelf_param := get_shelf["parameters"].([]any)[0].(map[string]any)
+	if shelf_param["description"] !=
+		"Segment of `name`, which has the form `shelves/{shelf}`." {
+		t.Errorf("unexpected GetShelf parameter: %v", shelf_param)
+	}
+	binding := get_op("/v1/books/{name}", "get")
+	if binding["operationId"] != "LibraryService_GetBook_2" {
+		t.Errorf("unexpected operationId for additional binding %v",
+			binding["operationId"])
+	}
+
+	create_book := get_op("/v1/shelves/{shelf}/books", "post")
+	if !reflect.DeepEqual(get_params(create_book),
+		map[string]string{"shelf": "path", "requestId": "query"}) {
+		t.Errorf("unexpected CreateBook parameters: %v",
+			get_params(create_book))
+	}
+	body_schema := dig(create_book, "requestBody", "content",
+		"application/json", "schema")
+	if body_schema["$ref"] != "#/components/schemas/library.v1.Book" {
+		t.Errorf("unexpected CreateBook body schema: %v", body_schema)
+	}
+
+	update_book := get_op("/v1/shelves/{shelf}/books/{book}", "patch")
+	if update_book["deprecated"] != true {
+		t.Errorf("UpdateBook isn't marked as deprecated")
+	}
+
+	list_books := get_op("/v1/shelves/{shelf}/books", "get")
+	response_schema := dig(list_books, "responses", "200", "content",
+		"application/json", "schema")
+	if response_schema["type"] != "array" {
+		t.Errorf("ListBooks response isn't the books array: %v",
+			response_schema)
+	}
+
+	schemas := doc["components"].(map[string]any)["schemas"].(map[string]any)
+	for _, name := range []string{"library.v1.Book", "library.v1.Ebook",
+		"library.v1.UpdateBookRequest", "google.rpc.Status"} {
+		if _, ok := schemas[name]; !ok {
+			t.Errorf("schema %s is missing", name)
+		}
+	}
+	if _, ok := schemas["library.v1.ListBooksRequest"]; ok {
+		t.Errorf("unreferenced schema library.v1.ListBooksRequest is included")
+	}
+
+	book := schemas["library.v1.Book"].(map[string]any)
+	properties := book["properties"].(map[string]any)
+	page_count := properties["pageCount"].(map[string]any)
+	if page_count["type"] != "string" {
+		t.Errorf("int64 field isn't encoded as a string: %v", page_count)
+	}
+	if _, ok := book["oneOf"]; !ok {
+		t.Errorf("Book schema is missing the oneOf constraint")
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	read_schema := func(out_dir, file_name string) map[string]any {
+		json_bytes, err := os.ReadFile(path.Join(out_dir, file_name))
+		if err != nil {
+			t.Fatalf("couldn't read JSON Schema output %s: %s", file_name,
+				err)
+		}
+		doc := make(map[string]any)
+		if err := json.Unmarshal(json_bytes, &doc); err != nil {
+			t.Fatalf("couldn't unmarshal JSON Schema output %s: %s",
+				file_name, err)
+		}
+		return doc
+	}
+
+	out_dir, ok := run_plugin(t, "data/jsonschema", "outfmt=jsonschema",
+		"tree.proto")
+	if !ok {
+		return
+	}
+
+	doc := read_schema(out_dir, "tree.v1.Node.schema.json")
+	if doc["$schema"] != "https://json-schema.org/draft/2020-12/schema" {
+		t.Errorf("unexpected $schema %v", doc["$schema"])
+	}
+	if doc["$ref"] != "#/$defs/tree.v1.Node" {
+		t.Errorf("unexpected $ref %v", doc["$ref"])
+	}
+	defs := doc["$defs"].(map[string]any)
+	if !reflect.DeepEqual(get_sorted_keys(defs),
+		[]string{"tree.v1.Kind", "tree.v1.Node"}) {
+		t.Errorf("unexpected definitions %v", get_sorted_keys(defs))
+	}
+
+	node := defs["tree.v1.Node"].(map[string]any)
+	properties := node["properties"].(map[string]any)
+	get_property := func(name string) map[string]any {
+		property, ok := properties[name].(map[string]any)
+		if !ok {
+			t.Fatalf("property %s is missing", name)
+		}
+		return property
+	}
+
+	if get_property("children")["items"].(map[string]any)["$ref"] !=
+		"#/$defs/tree.v1.Node" {
+		t.Errorf("recursive field children doesn't refer to Node")
+	}
+	named_children := get_property("namedChildren")
+	if named_children["additionalProperties"].(map[string]any)["$ref"] !=
+		"#/$defs/tree.v1.Node" {
+		t.Errorf("map field namedChildren doesn't refer to Node: %v",
+			named_children)
+	}
+	if get_property("sizeBytes")["type"] != "string" {
+		t.Errorf("uint64 field isn't encoded as a string")
+	}
+	if get_property("ttl")["type"] != "string" {
+		t.Errorf("Duration field isn't encoded as a string")
+	}
+	if get_property("ttlSeconds")["deprecated"] != true {
+		t.Errorf("deprecated field isn't marked as deprecated")
+	}
+	if one_of, ok := node["oneOf"].([]any); !ok || len(one_of) != 3 {
+		t.Errorf("unexpected oneOf constraint for Node: %v", node["oneOf"])
+	}
+
+	kind := defs["tree.v1.Kind"].(map[string]any)
+	if !reflect.DeepEqual(kind["enum"], []any{"KIND_UNSPECIFIED",
+		"KIND_FILE", "KIND_DIRECTORY"}) {
+		t.Errorf("unexpected enum values %v", kind["enum"])
+	}
+
+	if _, err := os.Stat(path.Join(out_dir,
+		"tree.v1.Node.NamedChildrenEntry.schema.json")); err == nil {
+		t.Errorf("schema was written for a map entry message")
+	}
+
+	out_dir, ok = run_plugin(t, "data/jsonschema",
+		"outfmt=jsonschema,schema_bundle", "tree.proto")
+	if !ok {
+		return
+	}
+	bundle := read_schema(out_dir, "schema.json")
+	if !reflect.DeepEqual(get_sorted_keys(bundle["$defs"].(map[string]any)),
+		[]string{"tree.v1.Forest", "tree.v1.Kind", "tree.v1.Node"}) {
+		t.Errorf("unexpected bundle definitions %v",
+			get_sorted_keys(bundle["$defs"].(map[string]any)))
+	}
+}
+
+func TestJSONSchemaImportedTypes(t *testing.T) {
+	// Only app.proto is generated, so Contact (defined in an imported file)
+	// has no definition and must not be referred to by `$ref`.
+	out_dir, ok := run_plugin(t, "data/imports", "outfmt=jsonschema",
+		"app/v1/app.proto")
+	if !ok {
+		return
+	}
+
+	json_bytes, err := os.ReadFile(path.Join(out_dir,
+		"app.v1.App.schema.json"))
+	if err != nil {
+		t.Fatalf("couldn't read JSON Schema output: %s", err)
+	}
+	doc := make(map[string]any)
+	if err := json.Unmarshal(json_bytes, &doc); err != nil {
+		t.Fatalf("couldn't unmarshal JSON Schema output: %s", err)
+	}
+
+	defs := doc["$defs"].(map[string]any)
+	if !reflect.DeepEqual(get_sorted_keys(defs), []string{"app.v1.App"}) {
+		t.Errorf("unexpected definitions %v", get_sorted_keys(defs))
+	}
+	app := defs["app.v1.App"].(map[string]any)
+	contact := app["properties"].(map[string]any)["contact"].(map[string]any)
+	if _, ok := contact["$ref"]; ok {
+		t.Errorf("field contact refers to an undefined type: %v", contact)
+	}
+	if contact["description"] != "Contact for the team that owns the app." {
+		t.Errorf("unexpected description for field contact: %v",
+			contact["description"])
+	}
+}
+
+func TestDiagrams(t *testing.T) {
+	files := []string{"shop/v1/order.proto", "common/v1/money.proto"}
+	read_output := func(out_dir, file_name string) string {
+		content, err := os.ReadFile(path.Join(out_dir, file_name))
+		if err != nil {
+			t.Fatalf("couldn't read diagram %s: %s", file_name, err)
+		}
+		return string(content)
+	}
+	check_contains := func(file_name, content string, expected []string) {
+		for _, line := range expected {
+			if !strings.Contains(content, line) {
+				t.Errorf("%s is missing %q:\n%s", file_name, line, content)
+			}
+		}
+	}
+
+	out_dir, ok := run_plugin(t, "data/diagrams", "outfmt=dot", files...)
+	if !ok {
+		return
+	}
+
+	check_contains("packages.dot", read_output(out_dir, "packages.dot"),
+		[]string{
+			`n0 [shape=folder, label="common.v1"];`,
+			`n1 [shape=folder, label="shop.v1"];`,
+			`n2 [shape=note, label="google/protobuf/timestamp.proto"];`,
+			"n1 -> n0;",
+			"n1 -> n2 [style=dashed];",
+		})
+	check_contains("services/shop.v1.OrderService.dot",
+		read_output(out_dir, "services/shop.v1.OrderService.dot"),
+		[]string{
+			`n0 [shape=component, label="shop.v1.OrderService"];`,
+			`n1 [shape=ellipse, label="GetOrder"];`,
+			`n1 -> n2 [label="request"];`,
+			`n1 -> n3 [label="response"];`,
+			`[label="stream response"];`,
+		})
+	check_contains("messages/shop.v1.Order.dot",
+		read_output(out_dir, "messages/shop.v1.Order.dot"),
+		[]string{
+			`n0 [shape=record, label="{shop.v1.Order|string id\lrepeated ` +
+				`LineItem items\lMoney total\lTimestamp created\lStatus ` +
+				`status\lmap\<string, Order\> related\l}"];`,
+			`[shape=record, label="{«enum»\nshop.v1.Status|` +
+				`STATUS_UNSPECIFIED\lSTATUS_OPEN\l}"];`,
+			`n0 -> n0 [label="related"];`,
+			`label="{shop.v1.Product|`,
+		})
+	if _, err := os.Stat(path.Join(out_dir,
+		"messages/shop.v1.Order.RelatedEntry.dot")); err == nil {
+		t.Errorf("diagram was written for a map entry message")
+	}
+
+	out_dir, ok = run_plugin(t, "data/diagrams",
+		"outfmt=mermaid,diagram_depth=1,diagram_exclude_well_known,"+
+			"diagram_cluster", files...)
+	if !ok {
+		return
+	}
+
+	packages := read_output(out_dir, "packages.mmd")
+	if strings.Contains(packages, "google/protobuf") {
+		t.Errorf("package graph includes well-known types:\n%s", packages)
+	}
+
+	order := read_output(out_dir, "messages/shop.v1.Order.mmd")
+	check_contains("messages/shop.v1.Order.mmd", order, []string{
+		"classDiagram",
+		"    namespace shop_v1 {",
+		`        class n0["Order"] {`,
+		"            map~string, Order~ related",
+		"    namespace common_v1 {",
+		`        class n2["Money"] {`,
+		"            <<enumeration>>",
+		"    n0 --> n1 : items",
+	})
+	for _, unexpected := range []string{"Timestamp\"]", "Product\"]"} {
+		if strings.Contains(order, unexpected) {
+			t.Errorf("messages/shop.v1.Order.mmd has %s:\n%s", unexpected,
+				order)
+		}
+	}
+
+	check_contains("services/shop.v1.OrderService.mmd",
+		read_output(out_dir, "services/shop.v1.OrderService.mmd"),
+		[]string{
+			"flowchart LR",
+			`    subgraph c0["shop.v1"]`,
+			`        n0[["OrderService"]]`,
+			`        n1(["GetOrder"])`,
+			`    n1 -->|"request"| n2`,
+		})
+}
+
+func TestAsciiDocAndRSTOutput(t *testing.T) {
+	files := []string{"catalog.proto", "units.proto"}
+	check_output := func(outfmt, file_name string, expected []string) {
+		out_dir, ok := run_plugin(t, "data/docformats", "outfmt="+outfmt,
+			files...)
+		if !ok {
+			return
+		}
+
+		content, err := os.ReadFile(path.Join(out_dir, file_name))
+		if err != nil {
+			t.Fatalf("couldn't read %s output: %s", outfmt, err)
+		}
+		for _, line := range expected {
+			if !strings.Contains(string(content), line) {
+				t.Errorf("%s output is missing %q:\n%s", outfmt, line,
+					content)
+			}
+		}
+	}
+
+	check_output("asciidoc", "catalog.v1.adoc", []string{
+		"= catalog.v1\n",
+		"* <<messages,Messages>>\n** <<catalog-v1-Product,Product>>\n",
+		"[[catalog-v1-Product]]\n=== Product\n",
+		"|[[catalog-v1-Product-sku]]`sku`\n|1\n|`string`\n|optional\n|\n" +
+			"|The SKU, either `abc\\|123` or a *legacy* id\nsuch as old_id.\n",
+		"|xref:units.v1.adoc#units-v1-Length[Length]\n",
+		"WARNING: *Deprecated.* Use Product instead.\n",
+	})
+
+	check_output("rst", "catalog.v1.rst", []string{
+		"==========\ncatalog.v1\n==========\n",
+		".. _catalog-v1-product:\n\nProduct\n~~~~~~~\n",
+		"   * - ``sku``\n     - 1\n     - ``string``\n     - optional\n" +
+			"     - \n     - The SKU, either ``abc|123`` or a \\*legacy\\* " +
+			"id\n       such as old\\_id.\n",
+		"     - :ref:`Length <units-v1-length>`\n",
+		".. warning::\n\n   **Deprecated.** Use Product instead.\n",
+		"* ``catalog.proto``\n\n  * ``owner``: catalog-team\n",
+	})
+}
+
+func TestDocSites(t *testing.T) {
+	files := []string{"shop/v1/order.proto", "common/v1/money.proto"}
+	read_output := func(out_dir, file_name string) string {
+		content, err := os.ReadFile(path.Join(out_dir, file_name))
+		if err != nil {
+			t.Fatalf("couldn't read %s: %s", file_name, err)
+		}
+		return string(content)
+	}
+	check_contains := func(file_name, content string, expected []string) {
+		for _, line := range expected {
+			if !strings.Contains(content, line) {
+				t.Errorf("%s is missing %q:\n%s", file_name, line, content)
+			}
+		}
+	}
+
+	out_dir, ok := run_plugin(t, "data/diagrams",
+		"outfmt=mkdocs,api_title=Shop API", files...)
+	if !ok {
+		return
+	}
+
+	config := make(map[string]any)
+	err := yaml.Unmarshal([]byte(read_output(out_dir, "mkdocs.yml")), &config)
+	if err != nil {
+		t.Fatalf("couldn't parse mkdocs.yml: %s", err)
+	}
+	if config["site_name"] != "Shop API" || config["docs_dir"] != "docs" {
+		t.Errorf("unexpected mkdocs.yml settings: %v", config)
+	}
+	expected_nav := []any{
+		map[string]any{"Home": "index.md"},
+		map[string]any{"common.v1": []any{
+			map[string]any{"Overview": "common.v1/index.md"},
+			map[string]any{"Messages": []any{
+				map[string]any{"Money": "common.v1/messages/Money.md"},
+			}},
+		}},
+		map[string]any{"shop.v1": []any{
+			map[string]any{"Overview": "shop.v1/index.md"},
+			map[string]any{"Services": []any{
+				map[string]any{
+					"OrderService": "shop.v1/services/OrderService.md",
+				},
+			}},
+			map[string]any{"Messages": []any{
+				map[string]any{"Order": "shop.v1/messages/Order.md"},
+				map[string]any{"LineItem": "shop.v1/messages/LineItem.md"},
+				map[string]any{"Product": "shop.v1/messages/Product.md"},
+				map[string]any{
+					"GetOrderRequest": "shop.v1/messages/GetOrderRequest.md",
+				},
+			}},
+			map[string]any{"Enums": []any{
+				map[string]any{"Status": "shop.v1/enums/Status.md"},
+			}},
+		}},
+	}
+	if !reflect.DeepEqual(config["nav"], expected_nav) {
+		t.Errorf("unexpected mkdocs.yml nav: %v", config["nav"])
+	}
+
+	check_contains("docs/index.md", read_output(out_dir, "docs/index.md"),
+		[]string{
+			"---\ntitle: \"Shop API\"\n---\n\n# Shop API\n",
+			"| [shop.v1](shop.v1/index.md) |",
+		})
+	check_contains("docs/shop.v1/index.md",
+		read_output(out_dir, "docs/shop.v1/index.md"), []string{
+			"| [Order](messages/Order.md#shop-v1-Order) | An order. |\n",
+			"## Files\n\n* `shop/v1/order.proto`\n",
+		})
+	check_contains("docs/shop.v1/messages/Order.md",
+		read_output(out_dir, "docs/shop.v1/messages/Order.md"), []string{
+			"---\ntitle: \"Order\"\ndescription: \"An order.\"\n---\n\n" +
+				"<a id=\"shop-v1-Order\"></a>\n# Order\n",
+			"[LineItem](LineItem.md#shop-v1-LineItem)",
+			"[Money](../../common.v1/messages/Money.md#common-v1-Money)",
+			"[Status](../enums/Status.md#shop-v1-Status)",
+		})
+
+	out_dir, ok = run_plugin(t, "data/diagrams", "outfmt=docusaurus",
+		files...)
+	if !ok {
+		return
+	}
+
+	check_contains("shop.v1/_category_.json",
+		read_output(out_dir, "shop.v1/_category_.json"), []string{
+			`"label": "shop.v1"`,
+			`"position": 2`,
+			`"id": "shop.v1/index"`,
+		})
+	check_contains("shop.v1/enums/_category_.json",
+		read_output(out_dir, "shop.v1/enums/_category_.json"), []string{
+			`"label": "Enums"`,
+			`"position": 3`,
+		})
+	check_contains("shop.v1/messages/LineItem.md",
+		read_output(out_dir, "shop.v1/messages/LineItem.md"), []string{
+			"---\ntitle: \"LineItem\"\nsidebar_label: \"LineItem\"\n" +
+				"sidebar_position: 2\ndescription: \"An item in an order.\"\n" +
+				"---\n",
+		})
+
+	out_dir, ok = run_plugin(t, "data/docformats",
+		"outfmt=docusaurus,docusaurus_sidebars", "catalog.proto",
+		"units.proto")
+	if !ok {
+		return
+	}
+
+	check_contains("catalog.v1/messages/Range.md",
+		read_output(out_dir, "catalog.v1/messages/Range.md"), []string{
+			"description: \"A range of values such as {1, 2} or <3.\"\n",
+			"A range of values such as \\{1, 2\\} or \\<3.\n",
+			"```json\n{\"bound\": \"<10\"}\n```\n\n" +
+				"Bounds are \\{inclusive\\}.\n",
+			"The bound, as `{lower}` or \\<upper\\>. |\n",
+		})
+	check_contains("sidebars.js", read_output(out_dir, "sidebars.js"),
+		[]string{
+			"const sidebars = {\n  \"apiSidebar\": [\n    \"index\",\n",
+			`"id": "catalog.v1/index"`,
+			`"catalog.v1/messages/Range"`,
+			"module.exports = sidebars;\n",
+		})
+	if _, err := os.Stat(path.Join(out_dir,
+		"catalog.v1/_category_.json")); err == nil {
+		t.Errorf("_category_.json was written with docusaurus_sidebars")
+	}
+}
+
+func TestTypeScript(t *testing.T) {
+	out_dir, ok := run_plugin(t, "data/typescript",
+		"outfmt=typescript", "shop.proto")
+	if !ok {
+		return
+	}
+
+	content, err := os.ReadFile(path.Join(out_dir, "types.d.ts"))
+	if err != nil {
+		t.Fatalf("couldn't read types.d.ts: %s", err)
+	}
+
+	expected := []string{
+		"export namespace shop.v1 {\n",
+		"   * Orders are immutable once placed; see *\\/ for details.\n",
+		"  export type Order = {\n",
+		"    items?: shop.v1.Order.Item[];\n",
+		"    itemsBySku?: { [key: string]: shop.v1.Order.Item };\n",
+		"    status?: shop.v1.Order.Status;\n",
+		"    createdAt?: string;\n",
+		"    totalCents?: string | null;\n",
+		"    metadata?: { \"@type\": string; [key: string]: unknown };\n",
+		"    note?: string;\n",
+		"    \"legacy-id\"?: string;\n",
+		"  } & (\n    /** How to pay for the order. */\n    | {\n" +
+			"        cardToken: string;\n        voucher?: never;\n      }\n",
+		"        /** @deprecated Use `card_token`. */\n" +
+			"        voucher: string;\n        cardToken?: never;\n",
+		"    | {\n        address?: never;\n        pickup?: never;\n      }\n  );\n",
+		"  export namespace Order {\n",
+		"      discounts?: (number | \"NaN\" | \"Infinity\" | \"-Infinity\")[];\n",
+		"    export type Status =\n      | \"STATUS_UNSPECIFIED\"\n" +
+			"      /** Waiting for payment. */\n      | \"STATUS_PENDING\"\n",
+		"      /** @deprecated */\n      | \"STATUS_LOST\";\n",
+		"  export interface Customer {\n",
+		"    orders?: shop.v1.Order[];\n",
+	}
+	for _, str := range expected {
+		if !strings.Contains(string(content), str) {
+			t.Errorf("types.d.ts is missing %q:\n%s", str, content)
+		}
+	}
+
+	// The map entry message shouldn't be declared.
+	if strings.Contains(string(content), "ItemsBySkuEntry") {
+		t.Errorf("map entry was declared:\n%s", content)
+	}
+}
+
+func TestInventory(t *testing.T) {
+	files := []string{"hr/v1/employee.proto", "hr/v1/options.proto"}
+	read_records := func(out_dir, file_name string, separator rune) [][]string {
+		content, err := os.ReadFile(path.Join(out_dir, file_name))
+		if err != nil {
+			t.Fatalf("couldn't read %s: %s", file_name, err)
+		}
+		reader := csv.NewReader(strings.NewReader(string(content)))
+		reader.Comma = separator
+		records, err := reader.ReadAll()
+		if err != nil {
+			t.Fatalf("couldn't parse %s: %s", file_name, err)
+		}
+		return records
+	}
+
+	for _, format := range []struct {
+		outfmt    string
+		ext       string
+		separator rune
+	}{{"csv", ".csv", ','}, {"tsv", ".tsv", '\t'}} {
+		out_dir, ok := run_plugin(t, "data/inventory",
+			"outfmt="+format.outfmt, files...)
+		if !ok {
+			return
+		}
+
+		fields := read_records(out_dir, "fields"+format.ext, format.separator)
+		expected := [][]string{
+			{"package", "message", "field", "number", "type", "label",
+				"deprecated", "description", "(pii)", "(retention)",
+				"defined_in"},
+			{"hr.v1", "hr.v1.Employee", "id", "1", "int64", "optional",
+				"false", "Employee ID.", "", "", "hr/v1/employee.proto"},
+			{"hr.v1", "hr.v1.Employee", "email", "2", "string", "optional",
+				"false", `Work email address, e.g. "a@b.example", for ` +
+					"contacting the employee.", "PII_KIND_CONTACT", "P7Y",
+				"hr/v1/employee.proto"},
+			{"hr.v1", "hr.v1.Employee", "bank_account", "3",
+				"hr.v1.BankAccount", "optional", "false",
+				"Bank account, for payroll.\n\nOnly visible to payroll.",
+				"PII_KIND_FINANCIAL", "", "hr/v1/employee.proto"},
+			{"hr.v1", "hr.v1.Employee", "labels", "4", "map<string, string>",
+				"repeated", "false", "", "", "", "hr/v1/employee.proto"},
+			{"hr.v1", "hr.v1.Employee", "legacy_email", "5", "string",
+				"optional", "true", "Use `email`.", "", "",
+				"hr/v1/employee.proto"},
+			{"hr.v1", "hr.v1.Employee", "status", "6",
+				"hr.v1.Employee.Status", "optional", "false", "", "", "",
+				"hr/v1/employee.proto"},
+			{"hr.v1", "hr.v1.BankAccount", "iban", "1", "string", "optional",
+				"false", "", "PII_KIND_FINANCIAL", "", "hr/v1/employee.proto"},
+		}
+		if !reflect.DeepEqual(fields, expected) {
+			t.Errorf("%s fields: expected %q, got %q", format.outfmt,
+				expected, fields)
+		}
+
+		values := read_records(out_dir, "enum_values"+format.ext,
+			format.separator)
+		expected_header := []string{"package", "enum", "value", "number",
+			"deprecated", "description", "(sensitive)", "defined_in"}
+		expected_row := []string{"hr.v1", "hr.v1.Employee.Status",
+			"STATUS_ON_LEAVE", "2", "false", "On leave.", "true",
+			"hr/v1/employee.proto"}
+		if len(values) != 7 {
+			t.Fatalf("%s: expected 7 enum value rows, got %d", format.outfmt,
+				len(values))
+		}
+		if !reflect.DeepEqual(values[0], expected_header) {
+			t.Errorf("%s enum values: expected header %q, got %q",
+				format.outfmt, expected_header, values[0])
+		}
+		found := false
+		for _, row := range values[1:] {
+			if reflect.DeepEqual(row, expected_row) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%s enum values: missing row %q in %q", format.outfmt,
+				expected_row, values)
+		}
+	}
+}
+
+func TestImportedFileOptionNames(t *testing.T) {
+	// Only app.proto is generated; the option is defined in an imported file.
+	data, ok := do_setup_dir(t, "data/imports", "", "app/v1/app.proto")
+	if !ok {
+		return
+	}
+
+	file_map := data["file_map"].(map[string]any)
+	file_data := file_map["app/v1/app.proto"].(map[string]any)
+	option_decls := file_data["option_decls"].([]any)
+	if len(option_decls) != 1 {
+		t.Fatalf("got %d option_decls, expected 1", len(option_decls))
+	}
+	check_fields_equal(t, option_decls[0].(map[string]any), map[string]any{
+		"name":         "(team.v1.owner)",
+		"field_number": float64(50100),
+		"custom":       true,
+	}, "owner option_decl", nil)
+}